@@ -0,0 +1,115 @@
+// Copyright 2018 Owkin, inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"chaincode/errors"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+)
+
+const (
+	// RoleAttribute is the name of the creator certificate attribute holding its role
+	RoleAttribute = "substra.role"
+	// accessControlKey is the ledger key of the channel access control policy
+	accessControlKey = "accessControl"
+)
+
+// AccessControl maps smart contracts to the roles allowed to invoke them.
+// Contracts which are not listed can be invoked by any identity.
+type AccessControl struct {
+	Roles map[string][]string `json:"roles"`
+}
+
+// getCreatorRole returns the role attribute of the transaction creator certificate
+func getCreatorRole(db *LedgerDB) (role string, found bool, err error) {
+	role, found, err = cid.GetAttributeValue(db.cc, RoleAttribute)
+	if err != nil {
+		return "", false, errors.Forbidden(err, "could not read the attributes of the transaction creator")
+	}
+	return
+}
+
+// hasAdminRights returns true if the contract is run by an approved governance
+// proposal. Admin rights are never read from the certificate attributes: they are
+// set by the CA of each organization, which could grant them to itself.
+func hasAdminRights(db *LedgerDB) bool {
+	return db.proposalKey != ""
+}
+
+// getAccessControl returns the access control policy stored on the ledger, if any
+func getAccessControl(db *LedgerDB) (AccessControl, error) {
+	policy := AccessControl{Roles: map[string][]string{}}
+	exists, err := db.KeyExists(accessControlKey)
+	if err != nil || !exists {
+		return policy, err
+	}
+	err = db.Get(accessControlKey, &policy)
+	return policy, err
+}
+
+// checkAccessControl returns an error if the access control policy restricts
+// the contract to roles the transaction creator does not have
+func checkAccessControl(db *LedgerDB, contract string) error {
+	policy, err := getAccessControl(db)
+	if err != nil {
+		return err
+	}
+	roles, ok := policy.Roles[contract]
+	if !ok {
+		return nil
+	}
+	role, found, err := getCreatorRole(db)
+	if err != nil {
+		return err
+	}
+	if !found || !stringInSlice(role, roles) {
+		return errors.Forbidden("%s requires one of the roles %v in the %s attribute of the creator certificate", contract, roles, RoleAttribute)
+	}
+	return nil
+}
+
+// updateAccessControl replaces the access control policy of the channel.
+// Only approved proposals can update it.
+func updateAccessControl(db *LedgerDB, args []string) (out outputAccessControl, err error) {
+	inp := inputAccessControl{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	if !hasAdminRights(db) {
+		err = errors.Forbidden("the access control policy can only be updated by an approved proposal")
+		return
+	}
+	policy := AccessControl{Roles: inp.Roles}
+	if err = db.Put(accessControlKey, policy); err != nil {
+		return
+	}
+	out.Fill(policy)
+	return
+}
+
+func queryAccessControl(db *LedgerDB, args []string) (out outputAccessControl, err error) {
+	if len(args) != 0 {
+		err = errors.BadRequest("incorrect number of arguments, expecting nothing")
+		return
+	}
+	policy, err := getAccessControl(db)
+	if err != nil {
+		return
+	}
+	out.Fill(policy)
+	return
+}
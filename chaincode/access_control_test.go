@@ -0,0 +1,84 @@
+// Copyright 2018 Owkin, inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessControl(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	mockStub.MockTransactionStart("42")
+	db := NewLedgerDB(mockStub)
+
+	inp := inputAccessControl{Roles: map[string][]string{
+		"registerAlgo":    {"admin", "data-scientist"},
+		"logStartTrain":   {"worker"},
+		"logSuccessTrain": {"worker"},
+	}}
+	_, err := updateAccessControl(db, assetToArgs(inp))
+	assert.Error(t, err, "identities without attributes cannot update the policy")
+	mockStub.CreatorAttributes = map[string]string{RoleAttribute: "admin"}
+	_, err = updateAccessControl(db, assetToArgs(inp))
+	assert.Error(t, err, "the certificate attributes do not grant admin rights")
+	mockStub.CreatorAttributes = nil
+	db.proposalKey = RandomUUID()
+	_, err = updateAccessControl(db, assetToArgs(inp))
+	db.proposalKey = ""
+	require.NoError(t, err)
+
+	policy, err := queryAccessControl(db, []string{})
+	require.NoError(t, err)
+	assert.Equal(t, inp.Roles, policy.Roles)
+
+	testTable := []struct {
+		name     string
+		contract string
+		role     string
+		allowed  bool
+	}{
+		{"listed role", "registerAlgo", "data-scientist", true},
+		{"unlisted role", "registerAlgo", "worker", false},
+		{"missing role", "registerAlgo", "", false},
+		{"worker role", "logStartTrain", "worker", true},
+		{"unrestricted contract", "registerDataManager", "", true},
+	}
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			mockStub.CreatorAttributes = nil
+			if test.role != "" {
+				mockStub.CreatorAttributes = map[string]string{RoleAttribute: test.role}
+			}
+			err := checkAccessControl(db, test.contract)
+			if test.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+
+	// the policy is enforced on invoke
+	require.NoError(t, db.Flush())
+	mockStub.CreatorAttributes = map[string]string{RoleAttribute: "worker"}
+	inpAlgo := inputAlgo{}
+	inpAlgo.createDefault()
+	resp := mockStub.MockInvoke(methodAndAssetToByte("registerAlgo", inpAlgo))
+	assert.EqualValues(t, 403, resp.Status, resp.Message)
+}
@@ -16,6 +16,14 @@ package main
 
 import (
 	"chaincode/errors"
+	"sort"
+)
+
+// List of the possible algo's status
+const (
+	AlgoStatusActive     = "active"
+	AlgoStatusDeprecated = "deprecated"
+	AlgoStatusArchived   = "archived"
 )
 
 // Set is a method of the receiver Algo. It uses inputAlgo fields to set the Algo
@@ -43,10 +51,85 @@ func (algo *Algo) Set(db *LedgerDB, inp inputAlgo) (err error) {
 	}
 	algo.Owner = owner
 	algo.Permissions = permissions
+	err = checkMetadata(db, inp.Metadata)
+	if err != nil {
+		return
+	}
 	algo.Metadata = inp.Metadata
+	algo.Status = AlgoStatusActive
+	err = algo.setParent(db, inp.ParentKey)
 	return
 }
 
+// GetStatus returns the status of the algo. Algos registered before the
+// introduction of the status are considered active.
+func (algo *Algo) GetStatus() string {
+	if algo.Status == "" {
+		return AlgoStatusActive
+	}
+	return algo.Status
+}
+
+// GetFamilyKey returns the key of the first version of the algo. Algos
+// registered before the introduction of versions are their own family.
+func (algo *Algo) GetFamilyKey() string {
+	if algo.FamilyKey == "" {
+		return algo.Key
+	}
+	return algo.FamilyKey
+}
+
+// GetVersion returns the version of the algo in its family, starting at 1
+func (algo *Algo) GetVersion() int {
+	if algo.Version == 0 {
+		return 1
+	}
+	return algo.Version
+}
+
+// setParent sets the algo as the next version of its parent. Without parent,
+// the algo is the first version of a new family.
+func (algo *Algo) setParent(db *LedgerDB, parentKey string) error {
+	algo.ParentKey = parentKey
+	if parentKey == "" {
+		algo.FamilyKey = algo.Key
+		algo.Version = 1
+		return nil
+	}
+	parent, err := db.GetAlgoOfAnyType(parentKey)
+	if err != nil {
+		return errors.BadRequest(err, "could not retrieve parent algo with key %s", parentKey)
+	}
+	if parent.AssetType != algo.AssetType {
+		return errors.BadRequest("parent algo %s is not a %s", parentKey, algo.AssetType)
+	}
+	if parent.Owner != algo.Owner {
+		return errors.Forbidden("%s is not the owner of the parent algo %s", algo.Owner, parentKey)
+	}
+	childKeys, err := db.GetIndexKeys("algo~parent~key", []string{"algo", parentKey})
+	if err != nil {
+		return err
+	}
+	if len(childKeys) > 0 {
+		return errors.Conflict("algo %s already has a next version: %s", parentKey, childKeys[0])
+	}
+	algo.FamilyKey = parent.GetFamilyKey()
+	algo.Version = parent.GetVersion() + 1
+	return nil
+}
+
+// checkUsable returns an error if the algo is archived and logs a warning if
+// it is deprecated
+func (algo *Algo) checkUsable() error {
+	switch algo.GetStatus() {
+	case AlgoStatusArchived:
+		return errors.BadRequest("algo %s is archived: %s", algo.Key, algo.StatusReason)
+	case AlgoStatusDeprecated:
+		logger.Warnf("algo %s is deprecated (replacement: %s): %s", algo.Key, algo.ReplacementKey, algo.StatusReason)
+	}
+	return nil
+}
+
 // -------------------------------------------------------------------------------------------
 // Smart contracts related to an algo
 // -------------------------------------------------------------------------------------------
@@ -74,6 +157,10 @@ func registerAlgo(db *LedgerDB, args []string) (resp outputKey, err error) {
 	if err != nil {
 		return
 	}
+	err = createAlgoVersionIndexes(db, algo)
+	if err != nil {
+		return
+	}
 	return outputKey{Key: algo.Key}, nil
 }
 
@@ -94,7 +181,7 @@ func queryAlgo(db *LedgerDB, args []string) (out outputAlgo, err error) {
 
 // queryAlgos returns all algos of the ledger
 func queryAlgos(db *LedgerDB, args []string) (outAlgos []outputAlgo, bookmark string, err error) {
-	inp := inputBookmark{}
+	inp := inputQueryAlgos{}
 	outAlgos = []outputAlgo{}
 
 	if len(args) > 1 {
@@ -109,7 +196,7 @@ func queryAlgos(db *LedgerDB, args []string) (outAlgos []outputAlgo, bookmark st
 		}
 	}
 
-	elementsKeys, bookmark, err := db.GetIndexKeysWithPagination("algo~owner~key", []string{"algo"}, OutputPageSize, inp.Bookmark)
+	elementsKeys, bookmark, err := getAlgoKeysWithPagination(db, AlgoType, inp)
 
 	if err != nil {
 		return
@@ -126,3 +213,148 @@ func queryAlgos(db *LedgerDB, args []string) (outAlgos []outputAlgo, bookmark st
 	}
 	return
 }
+
+// updateAlgoStatus changes the status of an algo, a composite algo or an
+// aggregate algo. Archived algos can no longer be used by new tuples.
+func updateAlgoStatus(db *LedgerDB, args []string) (resp outputKey, err error) {
+	inp := inputUpdateAlgoStatus{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	algo, err := db.GetAlgoOfAnyType(inp.Key)
+	if err != nil {
+		return
+	}
+	txCreator, err := GetTxCreator(db.cc)
+	if err != nil {
+		return
+	}
+	if txCreator != algo.Owner {
+		err = errors.Forbidden("%s is not the owner of the algo %s", txCreator, inp.Key)
+		return
+	}
+	if inp.ReplacementKey != "" {
+		if inp.ReplacementKey == inp.Key {
+			err = errors.BadRequest("algo %s cannot replace itself", inp.Key)
+			return
+		}
+		var replacement Algo
+		replacement, err = db.GetAlgoOfAnyType(inp.ReplacementKey)
+		if err != nil {
+			err = errors.BadRequest(err, "could not retrieve replacement algo with key %s", inp.ReplacementKey)
+			return
+		}
+		if replacement.AssetType != algo.AssetType {
+			err = errors.BadRequest("replacement algo %s is not a %s", inp.ReplacementKey, algo.AssetType)
+			return
+		}
+		if replacement.GetStatus() == AlgoStatusArchived {
+			err = errors.BadRequest("replacement algo %s is archived", inp.ReplacementKey)
+			return
+		}
+	}
+
+	algo.Status = inp.Status
+	algo.StatusReason = inp.Reason
+	algo.ReplacementKey = inp.ReplacementKey
+	if err = db.Put(algo.Key, algo); err != nil {
+		return
+	}
+	return outputKey{Key: algo.Key}, nil
+}
+
+// queryAlgoVersions returns all the versions of the family of an algo,
+// ordered from the first to the last one
+func queryAlgoVersions(db *LedgerDB, args []string) (outAlgos []outputAlgo, err error) {
+	inp := inputKey{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	algo, err := db.GetAlgoOfAnyType(inp.Key)
+	if err != nil {
+		return
+	}
+	familyKey := algo.GetFamilyKey()
+	keys, err := db.GetIndexKeys("algo~family~key", []string{"algo", familyKey})
+	if err != nil {
+		return
+	}
+	// the first version may have been registered before versions were indexed
+	if !stringInSlice(familyKey, keys) {
+		keys = append(keys, familyKey)
+	}
+	versions := []Algo{}
+	for _, key := range keys {
+		var version Algo
+		version, err = db.GetAlgoOfAnyType(key)
+		if err != nil {
+			return
+		}
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].GetVersion() < versions[j].GetVersion()
+	})
+	outAlgos = []outputAlgo{}
+	for _, version := range versions {
+		var out outputAlgo
+		out.Fill(version)
+		outAlgos = append(outAlgos, out)
+	}
+	return
+}
+
+// -------------------------------------------------------------------------------------------
+// Utils for algos
+// -------------------------------------------------------------------------------------------
+
+// createAlgoVersionIndexes indexes an algo under its family and its parent
+func createAlgoVersionIndexes(db *LedgerDB, algo Algo) error {
+	if err := db.CreateIndex("algo~family~key", []string{"algo", algo.FamilyKey, algo.Key}); err != nil {
+		return err
+	}
+	if algo.ParentKey == "" {
+		return nil
+	}
+	return db.CreateIndex("algo~parent~key", []string{"algo", algo.ParentKey, algo.Key})
+}
+
+func isAlgoType(assetType AssetType) bool {
+	return assetType == AlgoType || assetType == CompositeAlgoType || assetType == AggregateAlgoType
+}
+
+// getAlgoIndexPrefix returns the object type used in the indexes of an algo type
+func getAlgoIndexPrefix(assetType AssetType) string {
+	switch assetType {
+	case CompositeAlgoType:
+		return "compositeAlgo"
+	case AggregateAlgoType:
+		return "aggregateAlgo"
+	}
+	return "algo"
+}
+
+// getAlgoKeysWithPagination returns a page of the keys of the algos of a given
+// type, optionally restricted to the ones having a given status. The status is
+// read from the algos themselves since the ones registered before the
+// introduction of statuses have none.
+func getAlgoKeysWithPagination(db *LedgerDB, assetType AssetType, inp inputQueryAlgos) ([]string, string, error) {
+	prefix := getAlgoIndexPrefix(assetType)
+	keys, bookmark, err := db.GetIndexKeysWithPagination(prefix+"~owner~key", []string{prefix}, db.config.PageSize, inp.Bookmark)
+	if err != nil || inp.Status == "" {
+		return keys, bookmark, err
+	}
+	filteredKeys := []string{}
+	for _, key := range keys {
+		algo, err := db.GetAlgoOfAnyType(key)
+		if err != nil {
+			return nil, "", err
+		}
+		if algo.GetStatus() == inp.Status {
+			filteredKeys = append(filteredKeys, key)
+		}
+	}
+	return filteredKeys, bookmark, nil
+}
@@ -43,7 +43,13 @@ func (algo *AggregateAlgo) Set(db *LedgerDB, inp inputAggregateAlgo) (err error)
 	}
 	algo.Owner = owner
 	algo.Permissions = permissions
+	err = checkMetadata(db, inp.Metadata)
+	if err != nil {
+		return
+	}
 	algo.Metadata = inp.Metadata
+	algo.Status = AlgoStatusActive
+	err = algo.setParent(db, inp.ParentKey)
 	return
 }
 
@@ -74,6 +80,10 @@ func registerAggregateAlgo(db *LedgerDB, args []string) (resp outputKey, err err
 	if err != nil {
 		return
 	}
+	err = createAlgoVersionIndexes(db, algo.Algo)
+	if err != nil {
+		return
+	}
 	return outputKey{Key: inp.Key}, nil
 }
 
@@ -94,7 +104,7 @@ func queryAggregateAlgo(db *LedgerDB, args []string) (out outputAggregateAlgo, e
 
 // queryAggregateAlgos returns all algos of the ledger
 func queryAggregateAlgos(db *LedgerDB, args []string) (outAlgos []outputAggregateAlgo, bookmark string, err error) {
-	inp := inputBookmark{}
+	inp := inputQueryAlgos{}
 	outAlgos = []outputAggregateAlgo{}
 
 	if len(args) > 1 {
@@ -109,7 +119,7 @@ func queryAggregateAlgos(db *LedgerDB, args []string) (outAlgos []outputAggregat
 		}
 	}
 
-	elementsKeys, bookmark, err := db.GetIndexKeysWithPagination("aggregateAlgo~owner~key", []string{"aggregateAlgo"}, OutputPageSize, inp.Bookmark)
+	elementsKeys, bookmark, err := getAlgoKeysWithPagination(db, AggregateAlgoType, inp)
 
 	if err != nil {
 		return
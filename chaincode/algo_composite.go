@@ -43,7 +43,13 @@ func (algo *CompositeAlgo) Set(db *LedgerDB, inp inputCompositeAlgo) (err error)
 	}
 	algo.Owner = owner
 	algo.Permissions = permissions
+	err = checkMetadata(db, inp.Metadata)
+	if err != nil {
+		return
+	}
 	algo.Metadata = inp.Metadata
+	algo.Status = AlgoStatusActive
+	err = algo.setParent(db, inp.ParentKey)
 	return
 }
 
@@ -74,6 +80,10 @@ func registerCompositeAlgo(db *LedgerDB, args []string) (resp outputKey, err err
 	if err != nil {
 		return
 	}
+	err = createAlgoVersionIndexes(db, algo.Algo)
+	if err != nil {
+		return
+	}
 	return outputKey{Key: algo.Key}, nil
 }
 
@@ -94,7 +104,7 @@ func queryCompositeAlgo(db *LedgerDB, args []string) (out outputCompositeAlgo, e
 
 // queryCompositeAlgos returns all algos of the ledger
 func queryCompositeAlgos(db *LedgerDB, args []string) (outAlgos []outputCompositeAlgo, bookmark string, err error) {
-	inp := inputBookmark{}
+	inp := inputQueryAlgos{}
 	outAlgos = []outputCompositeAlgo{}
 
 	if len(args) > 1 {
@@ -109,7 +119,7 @@ func queryCompositeAlgos(db *LedgerDB, args []string) (outAlgos []outputComposit
 		}
 	}
 
-	elementsKeys, bookmark, err := db.GetIndexKeysWithPagination("compositeAlgo~owner~key", []string{"compositeAlgo"}, OutputPageSize, inp.Bookmark)
+	elementsKeys, bookmark, err := getAlgoKeysWithPagination(db, CompositeAlgoType, inp)
 
 	if err != nil {
 		return
@@ -69,10 +69,14 @@ func TestCompositeAlgo(t *testing.T) {
 				StorageAddress: inpAlgo.DescriptionStorageAddress,
 			},
 			Owner: workerA,
-			Permissions: outputPermissions{
-				Process: Permission{Public: true, AuthorizedIDs: []string{}},
+			Permissions: outputPermissionsFull{
+				outputPermissions: outputPermissions{Process: Permission{Public: true, AuthorizedIDs: []string{}, AuthorizedGroups: []string{}}},
+				Download:          Permission{Public: true, AuthorizedIDs: []string{}, AuthorizedGroups: []string{}},
 			},
-			Metadata: map[string]string{},
+			Metadata:  map[string]string{},
+			Status:    AlgoStatusActive,
+			FamilyKey: algoKey,
+			Version:   1,
 		},
 	}
 	assert.Exactly(t, expectedAlgo, algo)
@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type AlgoResponse struct {
@@ -65,10 +66,14 @@ func TestAlgo(t *testing.T) {
 			StorageAddress: inpAlgo.DescriptionStorageAddress,
 		},
 		Owner: workerA,
-		Permissions: outputPermissions{
-			Process: Permission{Public: true, AuthorizedIDs: []string{}},
+		Permissions: outputPermissionsFull{
+			outputPermissions: outputPermissions{Process: Permission{Public: true, AuthorizedIDs: []string{}, AuthorizedGroups: []string{}}},
+			Download:          Permission{Public: true, AuthorizedIDs: []string{}, AuthorizedGroups: []string{}},
 		},
-		Metadata: map[string]string{},
+		Metadata:  map[string]string{},
+		Status:    AlgoStatusActive,
+		FamilyKey: algoKey,
+		Version:   1,
 	}
 	assert.Exactly(t, expectedAlgo, algo)
 
@@ -82,3 +87,133 @@ func TestAlgo(t *testing.T) {
 	assert.Len(t, algos.Results, 1)
 	assert.Exactly(t, expectedAlgo, algos.Results[0], "return algo different from registered one")
 }
+
+func TestUpdateAlgoStatus(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	registerItem(t, *mockStub, "aggregateAlgo")
+	mockStub.MockTransactionStart("42")
+	db := NewLedgerDB(mockStub)
+
+	replacementKey := RandomUUID()
+	replacement := inputAlgo{Key: replacementKey}
+	replacement.fillDefaults()
+	_, err := registerAlgo(db, assetToArgs(replacement))
+	require.NoError(t, err)
+
+	inp := inputUpdateAlgoStatus{
+		Key:            algoKey,
+		Status:         AlgoStatusDeprecated,
+		Reason:         "buggy loss function",
+		ReplacementKey: replacementKey,
+	}
+
+	mockStub.Creator = workerB
+	_, err = updateAlgoStatus(db, assetToArgs(inp))
+	assert.Error(t, err, "only the owner can change the status of an algo")
+	mockStub.Creator = workerA
+
+	_, err = updateAlgoStatus(db, assetToArgs(inp))
+	require.NoError(t, err)
+	algo, err := queryAlgo(db, keyToArgs(algoKey))
+	require.NoError(t, err)
+	assert.Equal(t, AlgoStatusDeprecated, algo.Status)
+	assert.Equal(t, "buggy loss function", algo.StatusReason)
+	assert.Equal(t, replacementKey, algo.ReplacementKey)
+
+	// a deprecated algo can still be used
+	_, err = createTraintuple(db, assetToArgs(inputTraintuple{
+		Key:            RandomUUID(),
+		AlgoKey:        algoKey,
+		DataManagerKey: dataManagerKey,
+		DataSampleKeys: []string{trainDataSampleKey1, trainDataSampleKey2},
+	}))
+	assert.NoError(t, err)
+
+	inp.Status = AlgoStatusArchived
+	_, err = updateAlgoStatus(db, assetToArgs(inp))
+	require.NoError(t, err)
+	_, err = createTraintuple(db, assetToArgs(inputTraintuple{
+		Key:            RandomUUID(),
+		AlgoKey:        algoKey,
+		DataManagerKey: dataManagerKey,
+		DataSampleKeys: []string{trainDataSampleKey1, trainDataSampleKey2},
+	}))
+	assert.Error(t, err, "an archived algo cannot be used by a new traintuple")
+
+	algos, _, err := queryAlgos(db, assetToArgs(inputQueryAlgos{Status: AlgoStatusArchived}))
+	require.NoError(t, err)
+	require.Len(t, algos, 1)
+	assert.Equal(t, algoKey, algos[0].Key)
+	algos, _, err = queryAlgos(db, assetToArgs(inputQueryAlgos{Status: AlgoStatusActive}))
+	require.NoError(t, err)
+	require.Len(t, algos, 1)
+	assert.Equal(t, replacementKey, algos[0].Key)
+
+	// algos registered before the introduction of statuses are active
+	legacy := Algo{Key: RandomUUID(), AssetType: AlgoType, Owner: workerA}
+	require.NoError(t, db.Put(legacy.Key, legacy))
+	require.NoError(t, db.CreateIndex("algo~owner~key", []string{"algo", legacy.Owner, legacy.Key}))
+	algos, _, err = queryAlgos(db, assetToArgs(inputQueryAlgos{Status: AlgoStatusActive}))
+	require.NoError(t, err)
+	assert.Len(t, algos, 2)
+
+	// the replacement must be an algo of the same type
+	inp = inputUpdateAlgoStatus{Key: compositeAlgoKey, Status: AlgoStatusArchived, ReplacementKey: replacementKey}
+	_, err = updateAlgoStatus(db, assetToArgs(inp))
+	assert.Error(t, err)
+	inp.ReplacementKey = ""
+	_, err = updateAlgoStatus(db, assetToArgs(inp))
+	require.NoError(t, err)
+	compositeAlgos, _, err := queryCompositeAlgos(db, assetToArgs(inputQueryAlgos{Status: AlgoStatusArchived}))
+	require.NoError(t, err)
+	require.Len(t, compositeAlgos, 1)
+	assert.Equal(t, AlgoStatusArchived, compositeAlgos[0].Status)
+}
+
+func TestAlgoVersions(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	registerItem(t, *mockStub, "aggregateAlgo")
+	mockStub.MockTransactionStart("42")
+	db := NewLedgerDB(mockStub)
+
+	v2 := inputAlgo{Key: RandomUUID(), ParentKey: algoKey}
+	v2.fillDefaults()
+	_, err := registerAlgo(db, assetToArgs(v2))
+	require.NoError(t, err)
+	v3 := inputAlgo{Key: RandomUUID(), ParentKey: v2.Key}
+	v3.fillDefaults()
+	_, err = registerAlgo(db, assetToArgs(v3))
+	require.NoError(t, err)
+
+	// the chain is linear
+	fork := inputAlgo{Key: RandomUUID(), ParentKey: algoKey}
+	fork.fillDefaults()
+	_, err = registerAlgo(db, assetToArgs(fork))
+	assert.Error(t, err, "an algo can only have one next version")
+
+	// the chain belongs to a single owner
+	mockStub.Creator = workerB
+	other := inputAlgo{Key: RandomUUID(), ParentKey: v3.Key}
+	other.fillDefaults()
+	_, err = registerAlgo(db, assetToArgs(other))
+	assert.Error(t, err, "only the owner of the parent algo can register a new version")
+	mockStub.Creator = workerA
+
+	// the chain contains a single type of algo
+	composite := inputCompositeAlgo{inputAlgo{Key: RandomUUID(), ParentKey: v3.Key}}
+	composite.fillDefaults()
+	_, err = registerCompositeAlgo(db, assetToArgs(composite))
+	assert.Error(t, err, "a composite algo cannot be the next version of an algo")
+
+	versions, err := queryAlgoVersions(db, keyToArgs(v2.Key))
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	for i, key := range []string{algoKey, v2.Key, v3.Key} {
+		assert.Equal(t, key, versions[i].Key)
+		assert.Equal(t, i+1, versions[i].Version)
+		assert.Equal(t, algoKey, versions[i].FamilyKey)
+	}
+	assert.Equal(t, v2.Key, versions[2].ParentKey)
+}
@@ -0,0 +1,119 @@
+// Copyright 2018 Owkin, inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"chaincode/errors"
+)
+
+// channelConfigKey is the ledger key of the channel configuration
+const channelConfigKey = "channelConfig"
+
+// defaultChannelConfig returns the limits used when no configuration has been stored
+func defaultChannelConfig() ChannelConfig {
+	return ChannelConfig{
+		PageSize:         OutputPageSize,
+		MaxLogLength:     200,
+		MaxMetadataItems: 100,
+	}
+}
+
+// LoadChannelConfig reads the channel configuration from the ledger. It is
+// called once per transaction, the defaults are kept if none has been stored.
+func (db *LedgerDB) LoadChannelConfig() error {
+	exists, err := db.KeyExists(channelConfigKey)
+	if err != nil || !exists {
+		return err
+	}
+	config := ChannelConfig{}
+	if err := db.Get(channelConfigKey, &config); err != nil {
+		return err
+	}
+	db.config = config
+	return nil
+}
+
+// updateChannelConfig replaces the channel configuration. Only approved proposals
+// can update it.
+func updateChannelConfig(db *LedgerDB, args []string) (out outputChannelConfig, err error) {
+	inp := inputChannelConfig{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	if !hasAdminRights(db) {
+		err = errors.Forbidden("the channel configuration can only be updated by an approved proposal")
+		return
+	}
+	config := ChannelConfig(inp)
+	if err = db.Put(channelConfigKey, config); err != nil {
+		return
+	}
+	db.config = config
+	out.Fill(config)
+	return
+}
+
+func queryChannelConfig(db *LedgerDB, args []string) (out outputChannelConfig, err error) {
+	if len(args) != 0 {
+		err = errors.BadRequest("incorrect number of arguments, expecting nothing")
+		return
+	}
+	if err = db.LoadChannelConfig(); err != nil {
+		return
+	}
+	out.Fill(db.config)
+	return
+}
+
+// appendLog adds a tuple log to its previous logs. Only the end of the logs is
+// kept if the configuration sets a log retention.
+func appendLog(db *LedgerDB, logs, log string) (string, error) {
+	if len(log) > db.config.MaxLogLength {
+		return "", errors.BadRequest("log exceeds the maximum length of %d characters", db.config.MaxLogLength)
+	}
+	logs += log
+	if db.config.LogRetention > 0 && len(logs) > db.config.LogRetention {
+		logs = logs[len(logs)-db.config.LogRetention:]
+	}
+	return logs, nil
+}
+
+// checkMetadata returns an error if the metadata exceeds the configured size
+func checkMetadata(db *LedgerDB, metadata map[string]string) error {
+	if len(metadata) > db.config.MaxMetadataItems {
+		return errors.BadRequest("metadata exceeds the maximum of %d items", db.config.MaxMetadataItems)
+	}
+	return nil
+}
+
+// checkDataManagerType returns an error if the configuration restricts the data
+// manager types and does not allow this one
+func checkDataManagerType(db *LedgerDB, dataManagerType string) error {
+	allowed := db.config.AllowedDataManagerTypes
+	if len(allowed) > 0 && !stringInSlice(dataManagerType, allowed) {
+		return errors.BadRequest("data manager type %s is not allowed, expecting one of %v", dataManagerType, allowed)
+	}
+	return nil
+}
+
+// checkComputePlanSize returns an error if the compute plan would exceed the
+// configured maximum number of tuples
+func checkComputePlanSize(db *LedgerDB, size int) error {
+	if db.config.MaxComputePlanSize > 0 && size > db.config.MaxComputePlanSize {
+		return errors.BadRequest("compute plan exceeds the maximum of %d tuples", db.config.MaxComputePlanSize)
+	}
+	return nil
+}
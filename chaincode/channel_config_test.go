@@ -0,0 +1,112 @@
+// Copyright 2018 Owkin, inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelConfig(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	registerItem(t, *mockStub, "traintuple")
+	mockStub.MockTransactionStart("42")
+	db := NewLedgerDB(mockStub)
+
+	config, err := queryChannelConfig(db, []string{})
+	require.NoError(t, err)
+	assert.EqualValues(t, OutputPageSize, config.PageSize)
+	assert.Equal(t, 200, config.MaxLogLength)
+
+	inp := inputChannelConfig{
+		PageSize:                100,
+		MaxComputePlanSize:      2,
+		MaxLogLength:            8,
+		LogRetention:            15,
+		MaxMetadataItems:        1,
+		AllowedDataManagerTypes: []string{"images"},
+	}
+	_, err = updateChannelConfig(db, assetToArgs(inp))
+	assert.Error(t, err, "only approved proposals can update the configuration")
+	db.proposalKey = RandomUUID()
+	config, err = updateChannelConfig(db, assetToArgs(inp))
+	db.proposalKey = ""
+	require.NoError(t, err)
+	assert.Equal(t, 8, config.MaxLogLength)
+
+	// limits cannot be raised beyond their hard cap
+	inp.MaxLogLength = 100001
+	db.proposalKey = RandomUUID()
+	_, err = updateChannelConfig(db, assetToArgs(inp))
+	db.proposalKey = ""
+	assert.Error(t, err)
+	inp.MaxLogLength = 8
+
+	// logs are limited and only their end is kept
+	_, err = logStartTrain(db, assetToArgs(inputKey{Key: traintupleKey}))
+	require.NoError(t, err)
+	_, err = logFailTrain(db, assetToArgs(inputLogFailTrain{inputLog{Key: traintupleKey, Log: strings.Repeat("a", 9)}}))
+	assert.Error(t, err)
+	_, err = logFailTrain(db, assetToArgs(inputLogFailTrain{inputLog{Key: traintupleKey, Log: "failed"}}))
+	require.NoError(t, err)
+	logs, err := appendLog(db, strings.Repeat("a", 12), "failed")
+	require.NoError(t, err)
+	assert.Equal(t, "aaaaaaaaafailed", logs)
+
+	// metadata, data manager types and compute plan sizes are limited
+	inpDataManager := inputDataManager{Key: RandomUUID(), Type: "tabular"}
+	inpDataManager.fillDefaults()
+	_, err = registerDataManager(db, assetToArgs(inpDataManager))
+	assert.Error(t, err, "the data manager type is not allowed")
+	inpDataManager.Type = "images"
+	inpDataManager.Metadata = map[string]string{"a": "1", "b": "2"}
+	_, err = registerDataManager(db, assetToArgs(inpDataManager))
+	assert.Error(t, err, "too many metadata")
+	inpDataManager.Metadata = map[string]string{"a": "1"}
+	_, err = registerDataManager(db, assetToArgs(inpDataManager))
+	assert.NoError(t, err)
+
+	_, err = createComputePlanInternal(db, defaultComputePlan, "", nil, false)
+	assert.Error(t, err, "the compute plan exceeds the maximum size")
+
+	// limits can be raised above the defaults
+	inp.MaxLogLength = 500
+	inp.MaxMetadataItems = 150
+	db.proposalKey = RandomUUID()
+	_, err = updateChannelConfig(db, assetToArgs(inp))
+	db.proposalKey = ""
+	require.NoError(t, err)
+	_, err = appendLog(db, "", strings.Repeat("a", 300))
+	assert.NoError(t, err)
+	inpDataManager = inputDataManager{Key: RandomUUID(), Type: "images"}
+	inpDataManager.fillDefaults()
+	inpDataManager.Metadata = map[string]string{}
+	for i := 0; i < 120; i++ {
+		inpDataManager.Metadata[strconv.Itoa(i)] = "1"
+	}
+	_, err = registerDataManager(db, assetToArgs(inpDataManager))
+	assert.NoError(t, err)
+
+	// the configuration is loaded by each transaction
+	require.NoError(t, db.Flush())
+	inpDataManager = inputDataManager{Key: RandomUUID(), Type: "tabular"}
+	resp := mockStub.MockInvoke(inpDataManager.createDefault())
+	assert.EqualValues(t, 400, resp.Status, resp.Message)
+}
@@ -46,6 +46,9 @@ func (inpAggregatetuple *inputAggregatetuple) Fill(inpCP inputComputePlanAggrega
 	inpAggregatetuple.Tag = inpCP.Tag
 	inpAggregatetuple.Metadata = inpCP.Metadata
 	inpAggregatetuple.Worker = inpCP.Worker
+	inpAggregatetuple.WorkerSelector = inpCP.WorkerSelector
+	inpAggregatetuple.OutModelPermissionsRule = inpCP.OutModelPermissionsRule
+	inpAggregatetuple.OutModelPermissions = inpCP.OutModelPermissions
 
 	// Set the inModels by matching the id to tuples key previously
 	// encontered in this compute plan
@@ -134,11 +137,16 @@ func updateComputePlan(db *LedgerDB, args []string) (resp outputComputePlan, err
 }
 
 func createComputePlanInternal(db *LedgerDB, inp inputComputePlan, tag string, metadata map[string]string, cleanModels bool) (resp outputComputePlan, err error) {
+	err = checkMetadata(db, metadata)
+	if err != nil {
+		return
+	}
 	var computePlan ComputePlan
 	computePlan.State.Status = StatusWaiting
 	computePlan.Tag = tag
 	computePlan.Metadata = metadata
 	computePlan.CleanModels = cleanModels
+	computePlan.MinAggregationWorkers = inp.MinAggregationWorkers
 	err = computePlan.Create(db, inp.Key)
 	if err != nil {
 		return resp, err
@@ -160,6 +168,13 @@ func updateComputePlanInternal(db *LedgerDB, inp inputComputePlan) (resp outputC
 	if err != nil {
 		return resp, err
 	}
+	size := len(computePlan.TraintupleKeys) + len(inp.Traintuples) +
+		len(computePlan.AggregatetupleKeys) + len(inp.Aggregatetuples) +
+		len(computePlan.CompositeTraintupleKeys) + len(inp.CompositeTraintuples) +
+		len(computePlan.TesttupleKeys) + len(inp.Testtuples)
+	if err = checkComputePlanSize(db, size); err != nil {
+		return resp, err
+	}
 	IDToTrainTask := map[string]TrainTask{}
 	for ID, trainTask := range computePlan.IDToTrainTask {
 		IDToTrainTask[ID] = trainTask
@@ -169,6 +184,8 @@ func updateComputePlanInternal(db *LedgerDB, inp inputComputePlan) (resp outputC
 	if err != nil {
 		return resp, errors.BadRequest(err)
 	}
+	// The rank of a tuple is its depth in the DAG, so tasks on the same worker at
+	// the same depth share a rank: the worker/rank availability check is skipped.
 	for _, task := range DAG.OrderTasks {
 		switch task.TaskType {
 		case TraintupleType:
@@ -182,8 +199,6 @@ func updateComputePlanInternal(db *LedgerDB, inp inputComputePlan) (resp outputC
 				return resp, errors.BadRequest("traintuple ID %s: "+err.Error(), computeTraintuple.ID)
 			}
 
-			// Intentionally skip the compute plan availability check: since the transaction hasn't been
-			// committed yet, the index changes haven't been commited, so the check would always fail.
 			tupleKey, err = createTraintupleInternal(db, inpTraintuple, false)
 			if err != nil {
 				return resp, errors.BadRequest("traintuple ID %s: "+err.Error(), computeTraintuple.ID)
@@ -198,8 +213,6 @@ func updateComputePlanInternal(db *LedgerDB, inp inputComputePlan) (resp outputC
 			if err != nil {
 				return resp, errors.BadRequest("traintuple ID %s: "+err.Error(), computeCompositeTraintuple.ID)
 			}
-			// Intentionally skip the compute plan availability check: since the transaction hasn't been
-			// committed yet, the index changes haven't been commited, so the check would always fail.
 			tupleKey, err = createCompositeTraintupleInternal(db, inpCompositeTraintuple, false)
 			if err != nil {
 				return resp, errors.BadRequest("traintuple ID %s: "+err.Error(), computeCompositeTraintuple.ID)
@@ -214,8 +227,6 @@ func updateComputePlanInternal(db *LedgerDB, inp inputComputePlan) (resp outputC
 			if err != nil {
 				return resp, errors.BadRequest("traintuple ID %s: "+err.Error(), computeAggregatetuple.ID)
 			}
-			// Intentionally skip the compute plan availability check: since the transaction hasn't been
-			// committed yet, the index changes haven't been commited, so the check would always fail.
 			tupleKey, err = createAggregatetupleInternal(db, inpAggregatetuple, false)
 			if err != nil {
 				return resp, errors.BadRequest("traintuple ID %s: "+err.Error(), computeAggregatetuple.ID)
@@ -282,7 +293,7 @@ func queryComputePlans(db *LedgerDB, args []string) (outComputePlans []outputCom
 		}
 	}
 
-	computePlanKeys, bookmark, err := db.GetIndexKeysWithPagination("computePlan~key", []string{"computePlan"}, OutputPageSize, inp.Bookmark)
+	computePlanKeys, bookmark, err := db.GetIndexKeysWithPagination("computePlan~key", []string{"computePlan"}, db.config.PageSize, inp.Bookmark)
 
 	if err != nil {
 		return
@@ -1,6 +1,9 @@
 package main
 
-import "fmt"
+import (
+	"chaincode/errors"
+	"fmt"
+)
 
 // TryAddIntermediaryModel updates the list of intermediary models in use for this worker.
 // 1. It adds the provided modelKey to the list (only if the corresponding tuple has children).
@@ -156,9 +159,7 @@ func isModelInUse(db *LedgerDB, modelKey string) (bool, error) {
 		return false, err
 	}
 	if len(keys) == 0 {
-		// This occurs for the keys added during the same transaction. But
-		// thoses models can just be considered in use
-		return true, nil
+		return false, errors.NotFound("Could not find a model for key %s", modelKey)
 	}
 	children, err := getTupleChildren(db, keys[0], true)
 	if err != nil {
@@ -31,6 +31,12 @@ func (dataManager *DataManager) Set(db *LedgerDB, inp inputDataManager) (string,
 		Checksum:       inp.OpenerChecksum,
 		StorageAddress: inp.OpenerStorageAddress,
 	}
+	if err := checkDataManagerType(db, inp.Type); err != nil {
+		return "", err
+	}
+	if err := checkMetadata(db, inp.Metadata); err != nil {
+		return "", err
+	}
 	dataManager.Type = inp.Type
 	dataManager.Metadata = inp.Metadata
 	dataManager.Description = &ChecksumAddress{
@@ -49,9 +55,74 @@ func (dataManager *DataManager) Set(db *LedgerDB, inp inputDataManager) (string,
 	}
 
 	dataManager.Permissions = permissions
+	dataManager.UsagePolicy = newUsagePolicy(inp.UsagePolicy)
 	return dataManager.ObjectiveKey, nil
 }
 
+func newUsagePolicy(in *inputUsagePolicy) *UsagePolicy {
+	if in == nil {
+		return nil
+	}
+	policy := UsagePolicy(*in)
+	return &policy
+}
+
+// checkAlgo returns an error if the policy does not allow the algo to run on the data
+func (policy *UsagePolicy) checkAlgo(dataManagerKey, algoKey, algoOwner string) error {
+	if policy == nil || (len(policy.AllowedAlgoOwners) == 0 && len(policy.AllowedAlgoKeys) == 0) {
+		return nil
+	}
+	if stringInSlice(algoOwner, policy.AllowedAlgoOwners) || stringInSlice(algoKey, policy.AllowedAlgoKeys) {
+		return nil
+	}
+	return errors.Forbidden("the usage policy of dataManager %s does not allow algo %s", dataManagerKey, algoKey)
+}
+
+// checkTrainDataSamples returns an error if a traintuple uses fewer samples than the policy requires
+func (policy *UsagePolicy) checkTrainDataSamples(dataManagerKey string, dataSampleKeys []string) error {
+	if policy == nil || len(dataSampleKeys) >= policy.MinSamplesPerTraintuple {
+		return nil
+	}
+	return errors.Forbidden("the usage policy of dataManager %s requires at least %d data samples per traintuple", dataManagerKey, policy.MinSamplesPerTraintuple)
+}
+
+// checkTestDataSamples returns an error if a testtuple uses train data samples the policy keeps for training
+func (policy *UsagePolicy) checkTestDataSamples(dataManagerKey string, testOnly bool) error {
+	if policy == nil || !policy.ForbidTestOnTrainData || testOnly {
+		return nil
+	}
+	return errors.Forbidden("the usage policy of dataManager %s forbids testtuples on train data", dataManagerKey)
+}
+
+// checkModelPermissions returns an error if the policy keeps the models trained on the data private
+func (policy *UsagePolicy) checkModelPermissions(dataManagerKey string, permissions Permissions) error {
+	if policy == nil || !policy.PrivateModels || (!permissions.Process.Public && !permissions.Download.Public) {
+		return nil
+	}
+	return errors.Forbidden("the usage policy of dataManager %s forbids public models", dataManagerKey)
+}
+
+// restrictModelPermissions makes permissions private to the given nodes if the
+// policy keeps the models trained on the data private
+func (policy *UsagePolicy) restrictModelPermissions(permissions Permissions, nodeIDs []string) Permissions {
+	if policy == nil || !policy.PrivateModels {
+		return permissions
+	}
+	authorizedIDs := []string{}
+	for _, nodeID := range nodeIDs {
+		if !stringInSlice(nodeID, authorizedIDs) {
+			authorizedIDs = append(authorizedIDs, nodeID)
+		}
+	}
+	restrict := func(perm Permission) Permission {
+		if perm.Public {
+			return Permission{Public: false, AuthorizedIDs: authorizedIDs}
+		}
+		return perm
+	}
+	return Permissions{Process: restrict(permissions.Process), Download: restrict(permissions.Download)}
+}
+
 // setDataSample is a method checking the validity of inputDataSample to be registered in the ledger
 // and returning corresponding dataSample keys, associated dataManagers, testOnly and errors
 func setDataSample(db *LedgerDB, inp inputDataSample) (dataSampleKeys []string, dataSample DataSample, err error) {
@@ -225,6 +296,53 @@ func updateDataSample(db *LedgerDB, args []string) (resp outputKey, err error) {
 	return outputKey{Key: keysJSON}, nil
 }
 
+// revokeDataSample marks one or more dataSample as revoked so that they can no
+// longer be used by new tuples. Todo and waiting tuples using them are canceled.
+func revokeDataSample(db *LedgerDB, args []string) (resp outputRevokeDataSample, err error) {
+	inp := inputRevokeDataSample{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	resp.Keys = []string{}
+	resp.CanceledTupleKeys = []string{}
+	for _, dataSampleKey := range inp.Keys {
+		var dataSample DataSample
+		dataSample, err = db.GetDataSample(dataSampleKey)
+		if err != nil {
+			return
+		}
+		if err = checkDataSampleOwner(db, dataSample); err != nil {
+			return
+		}
+		if dataSample.Revoked {
+			continue
+		}
+		dataSample.Revoked = true
+		if err = db.Put(dataSampleKey, dataSample); err != nil {
+			return
+		}
+		resp.Keys = append(resp.Keys, dataSampleKey)
+
+		var tupleKeys []string
+		tupleKeys, err = db.GetIndexKeys("tuple~dataSample~key", []string{"tuple", dataSampleKey})
+		if err != nil {
+			return
+		}
+		for _, tupleKey := range tupleKeys {
+			var canceled bool
+			canceled, err = cancelTuple(db, tupleKey)
+			if err != nil {
+				return
+			}
+			if canceled {
+				resp.CanceledTupleKeys = append(resp.CanceledTupleKeys, tupleKey)
+			}
+		}
+	}
+	return
+}
+
 // updateDataManager associates a objectiveKey to an existing dataManager
 func updateDataManager(db *LedgerDB, args []string) (resp outputKey, err error) {
 	inp := inputUpdateDataManager{}
@@ -240,6 +358,33 @@ func updateDataManager(db *LedgerDB, args []string) (resp outputKey, err error)
 	return outputKey{Key: inp.DataManagerKey}, nil
 }
 
+// updateUsagePolicy replaces the usage policy of a dataManager. It only applies
+// to the tuples created afterwards.
+func updateUsagePolicy(db *LedgerDB, args []string) (resp outputKey, err error) {
+	inp := inputUpdateUsagePolicy{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	dataManager, err := db.GetDataManager(inp.DataManagerKey)
+	if err != nil {
+		return
+	}
+	txCreator, err := GetTxCreator(db.cc)
+	if err != nil {
+		return
+	}
+	if txCreator != dataManager.Owner {
+		err = errors.Forbidden("%s is not the owner of the dataManager %s", txCreator, dataManager.Key)
+		return
+	}
+	dataManager.UsagePolicy = newUsagePolicy(inp.UsagePolicy)
+	if err = db.Put(dataManager.Key, dataManager); err != nil {
+		return
+	}
+	return outputKey{Key: dataManager.Key}, nil
+}
+
 // queryDataManager returns dataManager and its key
 func queryDataManager(db *LedgerDB, args []string) (out outputDataManager, err error) {
 	inp := inputKey{}
@@ -276,7 +421,7 @@ func queryDataManagers(db *LedgerDB, args []string) (outDataManagers []outputDat
 		}
 	}
 
-	elementsKeys, bookmark, err := db.GetIndexKeysWithPagination("dataManager~owner~key", []string{"dataManager"}, OutputPageSize, inp.Bookmark)
+	elementsKeys, bookmark, err := db.GetIndexKeysWithPagination("dataManager~owner~key", []string{"dataManager"}, db.config.PageSize, inp.Bookmark)
 
 	if err != nil {
 		return
@@ -340,7 +485,7 @@ func queryDataSamples(db *LedgerDB, args []string) (outDataSamples []outputDataS
 		}
 	}
 
-	elementsKeys, bookmark, err := db.GetIndexKeysWithPagination("dataSample~dataManager~key", []string{"dataSample"}, OutputPageSize, inp.Bookmark)
+	elementsKeys, bookmark, err := db.GetIndexKeysWithPagination("dataSample~dataManager~key", []string{"dataSample"}, db.config.PageSize, inp.Bookmark)
 
 	if err != nil {
 		return
@@ -358,6 +503,35 @@ func queryDataSamples(db *LedgerDB, args []string) (outDataSamples []outputDataS
 	return
 }
 
+// queryDataSampleUsage returns the tuples which were trained or tested on a
+// dataSample, with the models they produced
+func queryDataSampleUsage(db *LedgerDB, args []string) (outTuples []outputDataSampleUsage, bookmark string, err error) {
+	inp := inputKeyBookmark{}
+	outTuples = []outputDataSampleUsage{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	if _, err = db.GetDataSample(inp.Key); err != nil {
+		return
+	}
+
+	tupleKeys, bookmark, err := db.GetIndexKeysWithPagination("tuple~dataSample~key", []string{"tuple", inp.Key}, db.config.PageSize, inp.Bookmark)
+	if err != nil {
+		return
+	}
+
+	for _, tupleKey := range tupleKeys {
+		var out outputDataSampleUsage
+		out, err = getOutputDataSampleUsage(db, tupleKey)
+		if err != nil {
+			return
+		}
+		outTuples = append(outTuples, out)
+	}
+	return
+}
+
 // -----------------------------------------------------------------
 // -------------------- DataSample / DataManager utils -----------------------
 // -----------------------------------------------------------------
@@ -411,12 +585,78 @@ func checkSameDataManager(db *LedgerDB, dataManagerKey string, dataSampleKeys []
 			err = errors.BadRequest("dataSample do not belong to the same dataManager")
 			return testOnly, trainOnly, err
 		}
+		if dataSample.Revoked {
+			err = errors.BadRequest("dataSample %s has been revoked", dataSampleKey)
+			return testOnly, trainOnly, err
+		}
 		testOnly = testOnly && dataSample.TestOnly
 		trainOnly = trainOnly && !dataSample.TestOnly
 	}
 	return testOnly, trainOnly, nil
 }
 
+// getOutputDataSampleUsage returns the summary of a tuple using a dataSample
+func getOutputDataSampleUsage(db *LedgerDB, tupleKey string) (out outputDataSampleUsage, err error) {
+	tupleType, err := db.GetAssetType(tupleKey)
+	if err != nil {
+		return
+	}
+	out.Key = tupleKey
+	out.Type = tupleType.String()
+	out.OutModels = []*KeyChecksum{}
+
+	switch tupleType {
+	case TraintupleType:
+		var tuple Traintuple
+		tuple, err = db.GetTraintuple(tupleKey)
+		if err != nil {
+			return
+		}
+		out.fillTuple(tuple.AlgoKey, tuple.ComputePlanKey, tuple.Creator, tuple.Status, tuple.Tag, tuple.Dataset.Worker)
+		if tuple.OutModel != nil {
+			out.OutModels = append(out.OutModels, &KeyChecksum{Key: tuple.OutModel.Key, Checksum: tuple.OutModel.Checksum})
+		}
+	case CompositeTraintupleType:
+		var tuple CompositeTraintuple
+		tuple, err = db.GetCompositeTraintuple(tupleKey)
+		if err != nil {
+			return
+		}
+		out.fillTuple(tuple.AlgoKey, tuple.ComputePlanKey, tuple.Creator, tuple.Status, tuple.Tag, tuple.Dataset.Worker)
+		if tuple.OutHeadModel.OutModel != nil {
+			out.OutModels = append(out.OutModels, tuple.OutHeadModel.OutModel)
+		}
+		if tuple.OutTrunkModel.OutModel != nil {
+			out.OutModels = append(out.OutModels, &KeyChecksum{Key: tuple.OutTrunkModel.OutModel.Key, Checksum: tuple.OutTrunkModel.OutModel.Checksum})
+		}
+	case TesttupleType:
+		var tuple Testtuple
+		tuple, err = db.GetTesttuple(tupleKey)
+		if err != nil {
+			return
+		}
+		out.fillTuple(tuple.AlgoKey, tuple.ComputePlanKey, tuple.Creator, tuple.Status, tuple.Tag, tuple.Dataset.Worker)
+	default:
+		err = errors.Internal("getOutputDataSampleUsage: unsupported tuple type %s", tupleType)
+	}
+	return
+}
+
+// getRevokedDataSampleKeys returns the keys of the revoked dataSample in a slice
+func getRevokedDataSampleKeys(db *LedgerDB, dataSampleKeys []string) ([]string, error) {
+	revokedKeys := []string{}
+	for _, dataSampleKey := range dataSampleKeys {
+		dataSample, err := db.GetDataSample(dataSampleKey)
+		if err != nil {
+			return revokedKeys, err
+		}
+		if dataSample.Revoked {
+			revokedKeys = append(revokedKeys, dataSampleKey)
+		}
+	}
+	return revokedKeys, nil
+}
+
 // getDataset returns all dataSample keys associated to a dataManager
 func getDataset(db *LedgerDB, dataManagerKey string, testOnly bool) ([]string, error) {
 	indexName := "dataSample~dataManager~testOnly~key"
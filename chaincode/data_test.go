@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestJsonInputsDataManager(t *testing.T) {
@@ -80,8 +81,9 @@ func TestDataManager(t *testing.T) {
 			StorageAddress: inpDataManager.DescriptionStorageAddress,
 			Checksum:       inpDataManager.DescriptionChecksum,
 		},
-		Permissions: outputPermissions{
-			Process: Permission{Public: true, AuthorizedIDs: []string{}},
+		Permissions: outputPermissionsFull{
+			outputPermissions: outputPermissions{Process: Permission{Public: true, AuthorizedIDs: []string{}, AuthorizedGroups: []string{}}},
+			Download:          Permission{Public: true, AuthorizedIDs: []string{}, AuthorizedGroups: []string{}},
 		},
 		Opener: &ChecksumAddress{
 			Checksum:       inpDataManager.OpenerChecksum,
@@ -195,3 +197,204 @@ func TestDataset(t *testing.T) {
 	assert.ElementsMatch(t, out.TrainDataSampleKeys, inpDataSample.Keys, "when querying dataManager dataSample, unexpected train keys")
 
 }
+
+func TestQueryDataSampleUsage(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	registerItem(t, *mockStub, "compositeTraintuple")
+	mockStub.MockTransactionStart("42")
+	db := NewLedgerDB(mockStub)
+
+	traintupleToDone(t, db, traintupleKey)
+	testtuple := inputTesttuple{Key: testtupleKey, TraintupleKey: traintupleKey, ObjectiveKey: objectiveKey}
+	_, err := createTesttuple(db, assetToArgs(testtuple))
+	require.NoError(t, err)
+
+	tuples, _, err := queryDataSampleUsage(db, assetToArgs(inputKeyBookmark{Key: trainDataSampleKey1}))
+	require.NoError(t, err)
+	require.Len(t, tuples, 2)
+	usages := map[string]outputDataSampleUsage{}
+	for _, tuple := range tuples {
+		usages[tuple.Key] = tuple
+	}
+	require.Contains(t, usages, traintupleKey)
+	assert.Equal(t, TraintupleType.String(), usages[traintupleKey].Type)
+	assert.Equal(t, StatusDone, usages[traintupleKey].Status)
+	assert.Equal(t, algoKey, usages[traintupleKey].AlgoKey)
+	require.Len(t, usages[traintupleKey].OutModels, 1)
+	assert.Equal(t, modelKey, usages[traintupleKey].OutModels[0].Key)
+	require.Contains(t, usages, compositeTraintupleKey)
+	assert.Equal(t, CompositeTraintupleType.String(), usages[compositeTraintupleKey].Type)
+	assert.Empty(t, usages[compositeTraintupleKey].OutModels)
+
+	tuples, _, err = queryDataSampleUsage(db, assetToArgs(inputKeyBookmark{Key: testDataSampleKey1}))
+	require.NoError(t, err)
+	require.Len(t, tuples, 1)
+	assert.Equal(t, testtupleKey, tuples[0].Key)
+	assert.Equal(t, TesttupleType.String(), tuples[0].Type)
+	assert.Equal(t, workerA, tuples[0].Worker)
+
+	_, _, err = queryDataSampleUsage(db, assetToArgs(inputKeyBookmark{Key: RandomUUID()}))
+	assert.Error(t, err)
+}
+
+func TestRevokeDataSample(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	registerItem(t, *mockStub, "traintuple")
+	mockStub.MockTransactionStart("42")
+	db := NewLedgerDB(mockStub)
+
+	// a done traintuple, a todo traintuple and its waiting child
+	traintupleToDone(t, db, traintupleKey)
+	todoTraintuple := inputTraintuple{Key: RandomUUID()}
+	todoTraintuple.createDefault()
+	todoTraintuple.DataSampleKeys = []string{trainDataSampleKey1}
+	_, err := createTraintuple(db, assetToArgs(todoTraintuple))
+	require.NoError(t, err)
+	childTraintuple := inputTraintuple{Key: RandomUUID()}
+	childTraintuple.createDefault()
+	childTraintuple.DataSampleKeys = []string{trainDataSampleKey2}
+	childTraintuple.InModels = []string{todoTraintuple.Key}
+	_, err = createTraintuple(db, assetToArgs(childTraintuple))
+	require.NoError(t, err)
+
+	inp := inputRevokeDataSample{Keys: []string{trainDataSampleKey1}}
+
+	mockStub.Creator = workerB
+	_, err = revokeDataSample(db, assetToArgs(inp))
+	assert.Error(t, err, "only the data sample owner can revoke it")
+	mockStub.Creator = workerA
+
+	db.event = nil
+	resp, err := revokeDataSample(db, assetToArgs(inp))
+	require.NoError(t, err)
+	assert.Equal(t, []string{trainDataSampleKey1}, resp.Keys)
+	assert.Equal(t, []string{todoTraintuple.Key}, resp.CanceledTupleKeys)
+	require.NotNil(t, db.event)
+	require.Len(t, db.event.Traintuples, 1, "the worker of the todo traintuple should be told about its cancellation")
+	assert.Equal(t, todoTraintuple.Key, db.event.Traintuples[0].Key)
+	assert.Equal(t, StatusCanceled, db.event.Traintuples[0].Status)
+
+	dataSample, err := db.GetDataSample(trainDataSampleKey1)
+	require.NoError(t, err)
+	assert.True(t, dataSample.Revoked)
+
+	traintuple, err := db.GetTraintuple(todoTraintuple.Key)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCanceled, traintuple.Status)
+	traintuple, err = db.GetTraintuple(childTraintuple.Key)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCanceled, traintuple.Status, "the cancellation should be propagated to waiting children")
+
+	model, err := queryModel(db, keyToArgs(modelKey))
+	require.NoError(t, err)
+	assert.Equal(t, []string{trainDataSampleKey1}, model.RevokedDataSampleKeys)
+
+	newTraintuple := inputTraintuple{Key: RandomUUID()}
+	newTraintuple.createDefault()
+	_, err = createTraintuple(db, assetToArgs(newTraintuple))
+	assert.Error(t, err, "a revoked data sample should not be usable in a new traintuple")
+
+	// revoking an already revoked data sample is a no-op
+	resp, err = revokeDataSample(db, assetToArgs(inp))
+	require.NoError(t, err)
+	assert.Empty(t, resp.Keys)
+}
+
+func TestRevokeTestDataSample(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	registerItem(t, *mockStub, "traintuple")
+	mockStub.MockTransactionStart("42")
+	db := NewLedgerDB(mockStub)
+
+	_, err := revokeDataSample(db, assetToArgs(inputRevokeDataSample{Keys: []string{testDataSampleKey1}}))
+	require.NoError(t, err)
+
+	testtuple := inputTesttuple{Key: RandomUUID(), TraintupleKey: traintupleKey, ObjectiveKey: objectiveKey}
+	_, err = createTesttuple(db, assetToArgs(testtuple))
+	assert.Error(t, err, "a certified testtuple should not use a revoked data sample")
+}
+
+func TestDataManagerUsagePolicy(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	registerWorker(mockStub, workerB)
+	registerItem(t, *mockStub, "aggregateAlgo")
+	mockStub.MockTransactionStart("42")
+	db := NewLedgerDB(mockStub)
+
+	setPolicy := func(policy inputUsagePolicy) {
+		_, err := updateUsagePolicy(db, assetToArgs(inputUpdateUsagePolicy{DataManagerKey: dataManagerKey, UsagePolicy: &policy}))
+		require.NoError(t, err)
+	}
+	createTraintuple := func() (Traintuple, error) {
+		inp := inputTraintuple{Key: RandomUUID()}
+		inp.createDefault()
+		out, err := createTraintuple(db, assetToArgs(inp))
+		if err != nil {
+			return Traintuple{}, err
+		}
+		return db.GetTraintuple(out.Key)
+	}
+
+	mockStub.Creator = workerB
+	_, err := updateUsagePolicy(db, assetToArgs(inputUpdateUsagePolicy{DataManagerKey: dataManagerKey}))
+	assert.Error(t, err, "only the owner can update the usage policy")
+	mockStub.Creator = workerA
+
+	publicTraintuple, err := createTraintuple()
+	require.NoError(t, err)
+	require.True(t, publicTraintuple.Permissions.Process.Public)
+
+	setPolicy(inputUsagePolicy{AllowedAlgoOwners: []string{workerB}})
+	_, err = createTraintuple()
+	assert.Error(t, err, "the algo owner is not allowed")
+
+	setPolicy(inputUsagePolicy{AllowedAlgoKeys: []string{algoKey}, MinSamplesPerTraintuple: 3})
+	_, err = createTraintuple()
+	assert.Error(t, err, "not enough data samples")
+
+	setPolicy(inputUsagePolicy{MinSamplesPerTraintuple: 2, PrivateModels: true, ForbidTestOnTrainData: true})
+	traintuple, err := createTraintuple()
+	require.NoError(t, err)
+	assert.False(t, traintuple.Permissions.Process.Public, "models trained on the data are kept private")
+	assert.Equal(t, []string{workerA}, traintuple.Permissions.Process.AuthorizedIDs)
+
+	compositeTraintuple := inputCompositeTraintuple{Key: RandomUUID()}
+	compositeTraintuple.fillDefaults()
+	compositeTraintuple.OutTrunkModelPermissions = inputPermissions{Process: inputPermission{Public: true, AuthorizedIDs: []string{}}}
+	out, err := createCompositeTraintuple(db, assetToArgs(compositeTraintuple))
+	require.NoError(t, err)
+	composite, err := db.GetCompositeTraintuple(out.Key)
+	require.NoError(t, err)
+	assert.False(t, composite.OutTrunkModel.Permissions.Process.Public, "trunk models are kept private")
+	assert.Equal(t, []string{workerA}, composite.OutTrunkModel.Permissions.Process.AuthorizedIDs)
+
+	aggregatetuple := inputAggregatetuple{Key: RandomUUID(), InModels: []string{publicTraintuple.Key, traintuple.Key}}
+	aggregatetuple.fillDefaults()
+	out, err = createAggregatetuple(db, assetToArgs(aggregatetuple))
+	require.NoError(t, err)
+	aggregated, err := db.GetAggregatetuple(out.Key)
+	require.NoError(t, err)
+	assert.False(t, aggregated.Permissions.Process.Public, "a public in-model does not make the aggregated model public")
+
+	testtuple := inputTesttuple{
+		Key:            RandomUUID(),
+		TraintupleKey:  traintuple.Key,
+		DataManagerKey: dataManagerKey,
+		DataSampleKeys: []string{trainDataSampleKey1},
+	}
+	testtuple.fillDefaults()
+	_, err = createTesttuple(db, assetToArgs(testtuple))
+	assert.Error(t, err, "testtuples on train data are forbidden")
+	testtuple.DataSampleKeys = []string{testDataSampleKey1}
+	_, err = createTesttuple(db, assetToArgs(testtuple))
+	assert.NoError(t, err)
+
+	dataManager, err := queryDataManager(db, keyToArgs(dataManagerKey))
+	require.NoError(t, err)
+	require.NotNil(t, dataManager.UsagePolicy)
+	assert.True(t, dataManager.UsagePolicy.PrivateModels)
+}
@@ -352,6 +352,14 @@ func prettyPrintStructElements(buf io.Writer, margin string, strucType reflect.T
 				fmt.Fprint(buf, ",\n")
 			}
 			continue
+		case reflect.Ptr:
+			if f.Type.Elem().Kind() == reflect.Struct {
+				fmt.Fprintf(buf, "%s\"%s\": (%s)", margin, f.Tag.Get("json"), f.Tag.Get("validate"))
+				prettyPrintStruct(buf, margin+" ", f.Type.Elem())
+				fmt.Fprint(buf, ",\n")
+				continue
+			}
+			fieldStr = fmt.Sprint(f.Type.Elem().Kind())
 		case reflect.Bool:
 			jsonTag := strings.Split(f.Tag.Get("json"), ",")
 			if len(jsonTag) > 1 {
@@ -0,0 +1,299 @@
+// Copyright 2018 Owkin, inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"chaincode/errors"
+)
+
+// List of the possible proposal's status
+const (
+	ProposalStatusPending  = "pending"
+	ProposalStatusApproved = "approved"
+	ProposalStatusRejected = "rejected"
+	ProposalStatusExecuted = "executed"
+)
+
+// createProposal submits an action to the vote of the active nodes. The quorum
+// defaults to, and cannot be lower than, a majority of the active nodes.
+func createProposal(db *LedgerDB, args []string) (out outputProposal, err error) {
+	inp := inputProposal{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	if err = checkProposalArgs(inp.Action, inp.Args); err != nil {
+		return
+	}
+	proposer, err := getActiveVoter(db)
+	if err != nil {
+		return
+	}
+	voters, err := countActiveNodes(db)
+	if err != nil {
+		return
+	}
+	// a proposal always needs the approval of a majority of the active nodes
+	majority := voters/2 + 1
+	quorum := inp.Quorum
+	if quorum == 0 {
+		quorum = majority
+	}
+	if quorum < majority {
+		err = errors.BadRequest("quorum %d is below the majority of the active nodes (%d)", quorum, majority)
+		return
+	}
+	if quorum > voters {
+		err = errors.BadRequest("quorum %d exceeds the number of active nodes (%d)", quorum, voters)
+		return
+	}
+	timestamp, err := db.GetTxTimestamp()
+	if err != nil {
+		return
+	}
+	proposal := Proposal{
+		Key:               inp.Key,
+		AssetType:         ProposalType,
+		Proposer:          proposer,
+		Description:       inp.Description,
+		Action:            inp.Action,
+		Args:              inp.Args,
+		Quorum:            quorum,
+		Votes:             []ProposalVote{},
+		Status:            ProposalStatusPending,
+		CreationTimestamp: timestamp,
+	}
+	if err = db.Add(proposal.Key, proposal); err != nil {
+		return
+	}
+	if err = db.CreateIndex("proposal~key", []string{"proposal", proposal.Key}); err != nil {
+		return
+	}
+	out.Fill(proposal)
+	return
+}
+
+// voteProposal records the vote of the transaction creator node. The proposal
+// is approved once the quorum is reached, and rejected once it cannot be anymore.
+func voteProposal(db *LedgerDB, args []string) (out outputProposal, err error) {
+	inp := inputVoteProposal{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	proposal, err := db.GetProposal(inp.Key)
+	if err != nil {
+		return
+	}
+	if proposal.Status != ProposalStatusPending {
+		err = errors.BadRequest("proposal %s is %s", proposal.Key, proposal.Status)
+		return
+	}
+	voter, err := getActiveVoter(db)
+	if err != nil {
+		return
+	}
+	for _, vote := range proposal.Votes {
+		if vote.NodeID == voter {
+			err = errors.Conflict("node %s has already voted on proposal %s", voter, proposal.Key)
+			return
+		}
+	}
+	timestamp, err := db.GetTxTimestamp()
+	if err != nil {
+		return
+	}
+	proposal.Votes = append(proposal.Votes, ProposalVote{NodeID: voter, Approve: inp.Approve, Timestamp: timestamp})
+
+	voters, err := countActiveNodes(db)
+	if err != nil {
+		return
+	}
+	approvals, rejections := 0, 0
+	for _, vote := range proposal.Votes {
+		if vote.Approve {
+			approvals++
+		} else {
+			rejections++
+		}
+	}
+	switch {
+	case approvals >= proposal.Quorum:
+		proposal.Status = ProposalStatusApproved
+	case voters-rejections < proposal.Quorum:
+		proposal.Status = ProposalStatusRejected
+	}
+	if err = db.Put(proposal.Key, proposal); err != nil {
+		return
+	}
+	out.Fill(proposal)
+	return
+}
+
+// executeProposal runs the action of an approved proposal. The action is run
+// with admin rights, whoever the transaction creator is.
+func executeProposal(db *LedgerDB, args []string) (out outputProposal, err error) {
+	inp := inputKey{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	proposal, err := db.GetProposal(inp.Key)
+	if err != nil {
+		return
+	}
+	if proposal.Status != ProposalStatusApproved {
+		err = errors.BadRequest("proposal %s is %s, it cannot be executed", proposal.Key, proposal.Status)
+		return
+	}
+	txCreator, err := GetTxCreator(db.cc)
+	if err != nil {
+		return
+	}
+	timestamp, err := db.GetTxTimestamp()
+	if err != nil {
+		return
+	}
+
+	db.proposalKey = proposal.Key
+	err = runProposalAction(db, proposal)
+	db.proposalKey = ""
+	if err != nil {
+		return
+	}
+
+	proposal.Status = ProposalStatusExecuted
+	proposal.ExecutionTimestamp = timestamp
+	proposal.ExecutedBy = txCreator
+	if err = db.Put(proposal.Key, proposal); err != nil {
+		return
+	}
+	out.Fill(proposal)
+	return
+}
+
+// runProposalAction calls the contract targeted by the proposal with its arguments
+func runProposalAction(db *LedgerDB, proposal Proposal) (err error) {
+	args := []string{proposal.Args}
+	switch proposal.Action {
+	case "updateAccessControl":
+		_, err = updateAccessControl(db, args)
+	case "updateChannelConfig":
+		_, err = updateChannelConfig(db, args)
+	case "updateNodeStatus":
+		_, err = updateNodeStatus(db, args)
+	case "updateObjectiveTestDataset":
+		_, err = updateObjectiveTestDataset(db, args)
+	default:
+		err = errors.BadRequest("action %s cannot be proposed", proposal.Action)
+	}
+	return
+}
+
+// checkProposalArgs validates the arguments of a proposal against the input
+// of its action, so that a malformed proposal is not put to the vote.
+func checkProposalArgs(action, proposalArgs string) (err error) {
+	args := []string{proposalArgs}
+	switch action {
+	case "updateAccessControl":
+		err = AssetFromJSON(args, &inputAccessControl{})
+	case "updateChannelConfig":
+		err = AssetFromJSON(args, &inputChannelConfig{})
+	case "updateNodeStatus":
+		err = AssetFromJSON(args, &inputUpdateNodeStatus{})
+	case "updateObjectiveTestDataset":
+		err = AssetFromJSON(args, &inputUpdateObjectiveTestDataset{})
+	default:
+		err = errors.BadRequest("action %s cannot be proposed", action)
+	}
+	return
+}
+
+func queryProposal(db *LedgerDB, args []string) (out outputProposal, err error) {
+	inp := inputKey{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	proposal, err := db.GetProposal(inp.Key)
+	if err != nil {
+		return
+	}
+	out.Fill(proposal)
+	return
+}
+
+func queryProposals(db *LedgerDB, args []string) (outProposals []outputProposal, bookmark string, err error) {
+	inp := inputBookmark{}
+	outProposals = []outputProposal{}
+
+	if len(args) > 1 {
+		err = errors.BadRequest("incorrect number of arguments, expecting at most one argument")
+		return
+	}
+
+	if len(args) == 1 && args[0] != "" {
+		err = AssetFromJSON(args, &inp)
+		if err != nil {
+			return
+		}
+	}
+
+	elementsKeys, bookmark, err := db.GetIndexKeysWithPagination("proposal~key", []string{"proposal"}, db.config.PageSize, inp.Bookmark)
+	if err != nil {
+		return
+	}
+	for _, key := range elementsKeys {
+		proposal, err := db.GetProposal(key)
+		if err != nil {
+			return outProposals, bookmark, err
+		}
+		var out outputProposal
+		out.Fill(proposal)
+		outProposals = append(outProposals, out)
+	}
+	return
+}
+
+// getActiveVoter returns the transaction creator if it is an active node
+func getActiveVoter(db *LedgerDB) (string, error) {
+	txCreator, err := GetTxCreator(db.cc)
+	if err != nil {
+		return "", err
+	}
+	node, err := db.GetNode(txCreator)
+	if err != nil {
+		return "", errors.Forbidden(err, "%s is not a registered node", txCreator)
+	}
+	if node.GetStatus() != NodeStatusActive {
+		return "", errors.Forbidden("node %s is %s", txCreator, node.GetStatus())
+	}
+	return txCreator, nil
+}
+
+// countActiveNodes returns the number of nodes which can vote on proposals
+func countActiveNodes(db *LedgerDB) (int, error) {
+	nodes, err := queryNodes(db, []string{})
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, node := range nodes {
+		if node.GetStatus() == NodeStatusActive {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2018 Owkin, inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGovernanceProposal(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	registerWorker(mockStub, workerB)
+	registerWorker(mockStub, workerC)
+	mockStub.MockTransactionStart("42")
+	db := NewLedgerDB(mockStub)
+
+	vote := func(node, key string, approve bool) (outputProposal, error) {
+		mockStub.Creator = node
+		defer func() { mockStub.Creator = workerA }()
+		return voteProposal(db, assetToArgs(inputVoteProposal{Key: key, Approve: approve}))
+	}
+
+	// a proposal which cannot reach its quorum anymore is rejected
+	rejected := inputProposal{
+		Key:    RandomUUID(),
+		Action: "updateAccessControl",
+		Args:   string(assetToJSON(inputAccessControl{Roles: map[string][]string{"registerAlgo": {"admin"}}})),
+		Quorum: 3,
+	}
+	_, err := createProposal(db, assetToArgs(rejected))
+	require.NoError(t, err)
+	proposal, err := vote(workerC, rejected.Key, false)
+	require.NoError(t, err)
+	assert.Equal(t, ProposalStatusRejected, proposal.Status)
+
+	// the quorum cannot be lower than a majority of the active nodes
+	unilateral := inputProposal{
+		Key:    RandomUUID(),
+		Action: "updateNodeStatus",
+		Args:   string(assetToJSON(inputUpdateNodeStatus{NodeID: workerC, Status: NodeStatusSuspended})),
+		Quorum: 1,
+	}
+	_, err = createProposal(db, assetToArgs(unilateral))
+	assert.Error(t, err)
+
+	// the arguments are validated against the action when the proposal is created
+	malformed := inputProposal{Key: RandomUUID(), Action: "updateNodeStatus", Args: rejected.Args}
+	_, err = createProposal(db, assetToArgs(malformed))
+	assert.Error(t, err)
+
+	// sensitive contracts cannot be called directly
+	_, err = updateAccessControl(db, assetToArgs(inputAccessControl{Roles: map[string][]string{}}))
+	assert.Error(t, err)
+	_, err = updateChannelConfig(db, assetToArgs(inputChannelConfig(defaultChannelConfig())))
+	assert.Error(t, err)
+	_, err = updateNodeStatus(db, assetToArgs(inputUpdateNodeStatus{NodeID: workerC, Status: NodeStatusSuspended}))
+	assert.Error(t, err)
+
+	// suspending a node with the approval of a majority of the nodes
+	inp := inputProposal{
+		Key:         RandomUUID(),
+		Description: "suspend node C",
+		Action:      "updateNodeStatus",
+		Args:        string(assetToJSON(inputUpdateNodeStatus{NodeID: workerC, Status: NodeStatusSuspended})),
+	}
+	proposal, err = createProposal(db, assetToArgs(inp))
+	require.NoError(t, err)
+	assert.Equal(t, 2, proposal.Quorum)
+	assert.Equal(t, ProposalStatusPending, proposal.Status)
+
+	_, err = executeProposal(db, keyToArgs(inp.Key))
+	assert.Error(t, err, "a pending proposal cannot be executed")
+
+	_, err = vote(workerA, inp.Key, true)
+	require.NoError(t, err)
+	_, err = vote(workerA, inp.Key, true)
+	assert.Error(t, err, "a node can only vote once")
+	proposal, err = vote(workerB, inp.Key, true)
+	require.NoError(t, err)
+	assert.Equal(t, ProposalStatusApproved, proposal.Status)
+	assert.Len(t, proposal.Votes, 2)
+
+	proposal, err = executeProposal(db, keyToArgs(inp.Key))
+	require.NoError(t, err)
+	assert.Equal(t, ProposalStatusExecuted, proposal.Status)
+	assert.Equal(t, workerA, proposal.ExecutedBy)
+	node, err := db.GetNode(workerC)
+	require.NoError(t, err)
+	assert.Equal(t, NodeStatusSuspended, node.Status)
+
+	_, err = executeProposal(db, keyToArgs(inp.Key))
+	assert.Error(t, err, "a proposal can only be executed once")
+	_, err = vote(workerC, inp.Key, true)
+	assert.Error(t, err, "suspended nodes cannot vote")
+
+	proposals, _, err := queryProposals(db, []string{})
+	require.NoError(t, err)
+	assert.Len(t, proposals, 2)
+}
@@ -38,9 +38,20 @@ type inputObjective struct {
 	MetricsName               string            `validate:"required,gte=1,lte=100" json:"metrics_name"`
 	MetricsChecksum           string            `validate:"required,len=64,hexadecimal" json:"metrics_checksum"`
 	MetricsStorageAddress     string            `validate:"required,url" json:"metrics_storage_address"`
+	MetricNames               []string          `validate:"omitempty,unique,lte=20,dive,gte=1,lte=50" json:"metric_names"`
 	TestDataset               inputDataset      `validate:"omitempty" json:"test_dataset"`
 	Permissions               inputPermissions  `validate:"required" json:"permissions"`
-	Metadata                  map[string]string `validate:"lte=100,dive,keys,lte=50,endkeys,lte=100" json:"metadata"`
+	Metadata                  map[string]string `validate:"dive,keys,lte=50,endkeys,lte=100" json:"metadata"`
+	Challenge                 inputChallenge    `validate:"omitempty" json:"challenge"`
+	Blind                     bool              `json:"blind"`
+}
+
+// inputChallenge is the representation of input args to run an objective as a challenge.
+// Timestamps are in seconds since the epoch and are compared to the transactions timestamps.
+type inputChallenge struct {
+	OpeningTimestamp              int64 `validate:"omitempty,gte=0" json:"opening_timestamp"`
+	ClosingTimestamp              int64 `validate:"omitempty,gte=0" json:"closing_timestamp"`
+	MaxCertifiedTesttuplesPerNode int   `validate:"omitempty,gte=0" json:"max_certified_testtuples_per_node"`
 }
 
 // inputDataset is the representation in input args to register a dataset
@@ -58,7 +69,22 @@ type inputAlgo struct {
 	DescriptionChecksum       string            `validate:"required,len=64,hexadecimal" json:"description_checksum"`
 	DescriptionStorageAddress string            `validate:"required,url" json:"description_storage_address"`
 	Permissions               inputPermissions  `validate:"required" json:"permissions"`
-	Metadata                  map[string]string `validate:"lte=100,dive,keys,lte=50,endkeys,lte=100" json:"metadata"`
+	Metadata                  map[string]string `validate:"dive,keys,lte=50,endkeys,lte=100" json:"metadata"`
+	ParentKey                 string            `validate:"omitempty,len=36" json:"parent_key"`
+}
+
+// inputUpdateAlgoStatus is the representation of input args to change the status of an algo
+type inputUpdateAlgoStatus struct {
+	Key            string `validate:"required,len=36" json:"key"`
+	Status         string `validate:"required,oneof=active deprecated archived" json:"status"`
+	Reason         string `validate:"lte=200" json:"reason"`
+	ReplacementKey string `validate:"omitempty,len=36" json:"replacement_key"`
+}
+
+// inputQueryAlgos is the representation of input args to list algos
+type inputQueryAlgos struct {
+	Bookmark string `json:"bookmark"`
+	Status   string `validate:"omitempty,oneof=active deprecated archived" json:"status"`
 }
 
 // inputDataManager is the representation of input args to register a DataManager
@@ -72,7 +98,25 @@ type inputDataManager struct {
 	DescriptionStorageAddress string            `validate:"required,url" json:"description_storage_address"`
 	ObjectiveKey              string            `validate:"omitempty,len=36" json:"objective_key"` //`validate:"required"`
 	Permissions               inputPermissions  `validate:"required" json:"permissions"`
-	Metadata                  map[string]string `validate:"lte=100,dive,keys,lte=50,endkeys,lte=100" json:"metadata"`
+	Metadata                  map[string]string `validate:"dive,keys,lte=50,endkeys,lte=100" json:"metadata"`
+
+	UsagePolicy *inputUsagePolicy `validate:"omitempty" json:"usage_policy"`
+}
+
+// inputUsagePolicy is the representation of the usage policy of a dataManager
+type inputUsagePolicy struct {
+	AllowedAlgoOwners       []string `validate:"omitempty,unique" json:"allowed_algo_owners"`
+	AllowedAlgoKeys         []string `validate:"omitempty,unique,dive,len=36" json:"allowed_algo_keys"`
+	MinSamplesPerTraintuple int      `validate:"gte=0" json:"min_samples_per_traintuple"`
+	ForbidTestOnTrainData   bool     `json:"forbid_test_on_train_data"`
+	PrivateModels           bool     `json:"private_models"`
+	MinAggregationWorkers   int      `validate:"gte=0" json:"min_aggregation_workers"`
+}
+
+// inputUpdateUsagePolicy is the representation of input args to replace the usage policy of a dataManager
+type inputUpdateUsagePolicy struct {
+	DataManagerKey string            `validate:"required,len=36" json:"data_manager_key"`
+	UsagePolicy    *inputUsagePolicy `validate:"omitempty" json:"usage_policy"`
 }
 
 // inputUpdateDataManager is the representation of input args to update a dataManager with a objective
@@ -81,6 +125,13 @@ type inputUpdateDataManager struct {
 	ObjectiveKey   string `validate:"required,len=36" json:"objective_key"`
 }
 
+// inputUpdateObjectiveTestDataset is the representation of input args to replace the test dataset of an objective
+type inputUpdateObjectiveTestDataset struct {
+	ObjectiveKey   string   `validate:"required,len=36" json:"objective_key"`
+	DataManagerKey string   `validate:"required,len=36" json:"data_manager_key"`
+	DataSampleKeys []string `validate:"required,unique,gt=0,dive,len=36" json:"data_sample_keys"`
+}
+
 // inputDataSample is the representation of input args to register one or more dataSample
 type inputDataSample struct {
 	Keys            []string `validate:"required,dive,len=36" json:"keys"`
@@ -94,6 +145,11 @@ type inputUpdateDataSample struct {
 	DataManagerKeys []string `validate:"required,dive,len=36" json:"data_manager_keys"`
 }
 
+// inputRevokeDataSample is the representation of input args to revoke one or more dataSample
+type inputRevokeDataSample struct {
+	Keys []string `validate:"required,dive,len=36" json:"keys"`
+}
+
 // inputTraintuple is the representation of input args to register a Traintuple
 type inputTraintuple struct {
 	Key            string            `validate:"required,len=36" json:"key"`
@@ -104,7 +160,7 @@ type inputTraintuple struct {
 	ComputePlanKey string            `validate:"required_with=Rank" json:"compute_plan_key"`
 	Rank           string            `json:"rank"`
 	Tag            string            `validate:"omitempty,lte=64" json:"tag"`
-	Metadata       map[string]string `validate:"lte=100,dive,keys,lte=50,endkeys,lte=100" json:"metadata"`
+	Metadata       map[string]string `validate:"dive,keys,lte=50,endkeys,lte=100" json:"metadata"`
 }
 
 // inputTestuple is the representation of input args to register a Testtuple
@@ -114,7 +170,7 @@ type inputTesttuple struct {
 	DataSampleKeys []string          `validate:"omitempty,dive,len=36" json:"data_sample_keys"`
 	ObjectiveKey   string            `validate:"required,len=36" json:"objective_key"`
 	Tag            string            `validate:"omitempty,lte=64" json:"tag"`
-	Metadata       map[string]string `validate:"omitempty,lte=100,dive,keys,lte=50,endkeys,lte=100" json:"metadata"`
+	Metadata       map[string]string `validate:"omitempty,dive,keys,lte=50,endkeys,lte=100" json:"metadata"`
 	TraintupleKey  string            `validate:"required,len=36" json:"traintuple_key"`
 }
 
@@ -126,13 +182,19 @@ type inputBookmark struct {
 	Bookmark string `json:"bookmark"`
 }
 
+type inputKeyBookmark struct {
+	Key      string `validate:"required,len=36" json:"key"`
+	Bookmark string `json:"bookmark"`
+}
+
 type inputLogSuccessTrain struct {
 	inputLog
 	OutModel inputKeyChecksumAddress `validate:"required" json:"out_model"`
 }
 type inputLogSuccessTest struct {
 	inputLog
-	Perf float32 `validate:"omitempty" json:"perf"`
+	Perf  *float32           `validate:"omitempty" json:"perf"`
+	Perfs map[string]float32 `validate:"omitempty,lte=20" json:"perfs"`
 }
 type inputLogFailTrain struct {
 	inputLog
@@ -142,7 +204,7 @@ type inputLogFailTest struct {
 }
 type inputLog struct {
 	Key string `validate:"required,len=36" json:"key"`
-	Log string `validate:"lte=200" json:"log"`
+	Log string `json:"log"`
 }
 
 type inputKeyChecksum struct {
@@ -169,6 +231,8 @@ type inputComputePlan struct {
 	Aggregatetuples      []inputComputePlanAggregatetuple      `validate:"omitempty" json:"aggregatetuples"`
 	CompositeTraintuples []inputComputePlanCompositeTraintuple `validate:"omitempty" json:"composite_traintuples"`
 	Testtuples           []inputComputePlanTesttuple           `validate:"omitempty" json:"testtuples"`
+
+	MinAggregationWorkers int `validate:"gte=0" json:"min_aggregation_workers"` // minimum number of distinct workers contributing to an aggregatetuple, only read at creation
 }
 
 // inputNewComputePlan represent the set of tuples to be added to the compute
@@ -176,7 +240,7 @@ type inputComputePlan struct {
 type inputNewComputePlan struct {
 	CleanModels bool              `json:"clean_models"` // whether or not to delete intermediary models
 	Tag         string            `validate:"omitempty,lte=64" json:"tag"`
-	Metadata    map[string]string `validate:"lte=100,dive,keys,lte=50,endkeys,lte=100" json:"metadata"`
+	Metadata    map[string]string `validate:"dive,keys,lte=50,endkeys,lte=100" json:"metadata"`
 	inputComputePlan
 }
 
@@ -188,7 +252,7 @@ type inputComputePlanTraintuple struct {
 	ID             string            `validate:"required,lte=64" json:"id"`
 	InModelsIDs    []string          `validate:"omitempty,dive,lte=64" json:"in_models_ids"`
 	Tag            string            `validate:"omitempty,lte=64" json:"tag"`
-	Metadata       map[string]string `validate:"omitempty,lte=100,dive,keys,lte=50,endkeys,lte=100" json:"metadata"`
+	Metadata       map[string]string `validate:"omitempty,dive,keys,lte=50,endkeys,lte=100" json:"metadata"`
 }
 
 type inputComputePlanAggregatetuple struct {
@@ -197,8 +261,13 @@ type inputComputePlanAggregatetuple struct {
 	ID          string            `validate:"required,lte=64" json:"id"`
 	InModelsIDs []string          `validate:"omitempty,dive,lte=64" json:"in_models_ids"`
 	Tag         string            `validate:"omitempty,lte=64" json:"tag"`
-	Metadata    map[string]string `validate:"omitempty,lte=100,dive,keys,lte=50,endkeys,lte=100" json:"metadata"`
-	Worker      string            `validate:"required" json:"worker"`
+	Metadata    map[string]string `validate:"omitempty,dive,keys,lte=50,endkeys,lte=100" json:"metadata"`
+	Worker      string            `validate:"required_without=WorkerSelector" json:"worker"`
+
+	WorkerSelector *inputWorkerSelector `json:"worker_selector"`
+
+	OutModelPermissionsRule string            `validate:"omitempty,oneof=union intersection override" json:"out_model_permissions_rule"`
+	OutModelPermissions     *inputPermissions `json:"out_model_permissions"`
 }
 
 type inputComputePlanCompositeTraintuple struct {
@@ -211,7 +280,7 @@ type inputComputePlanCompositeTraintuple struct {
 	InTrunkModelID           string            `validate:"required_with=InHeadModelID,omitempty,len=64,hexadecimal" json:"in_trunk_model_id"`
 	OutTrunkModelPermissions inputPermissions  `validate:"required" json:"out_trunk_model_permissions"`
 	Tag                      string            `validate:"omitempty,lte=64" json:"tag"`
-	Metadata                 map[string]string `validate:"omitempty,lte=100,dive,keys,lte=50,endkeys,lte=100" json:"metadata"`
+	Metadata                 map[string]string `validate:"omitempty,dive,keys,lte=50,endkeys,lte=100" json:"metadata"`
 }
 
 type inputComputePlanTesttuple struct {
@@ -220,20 +289,163 @@ type inputComputePlanTesttuple struct {
 	DataSampleKeys []string          `validate:"omitempty,dive,len=36" json:"data_sample_keys"`
 	ObjectiveKey   string            `validate:"required,len=36" json:"objective_key"`
 	Tag            string            `validate:"omitempty,lte=64" json:"tag"`
-	Metadata       map[string]string `validate:"omitempty,lte=100,dive,keys,lte=50,endkeys,lte=100" json:"metadata"`
+	Metadata       map[string]string `validate:"omitempty,dive,keys,lte=50,endkeys,lte=100" json:"metadata"`
 	TraintupleID   string            `validate:"required,lte=64" json:"traintuple_id"`
 }
 
 type inputLeaderboard struct {
-	ObjectiveKey   string `validate:"omitempty,len=36" json:"objective_key"`
-	AscendingOrder bool   `json:"ascendingOrder,required"`
+	ObjectiveKey      string `validate:"omitempty,len=36" json:"objective_key"`
+	AscendingOrder    bool   `json:"ascendingOrder,required"`
+	BestPerAlgoFamily bool   `json:"best_per_algo_family"`
+	Bookmark          string `json:"bookmark"`
+	ComputePlanKey    string `validate:"omitempty,len=36" json:"compute_plan_key"`
+	DoneAfter         int64  `validate:"omitempty,gte=0" json:"done_after"`
+	DoneBefore        int64  `validate:"omitempty,gte=0" json:"done_before"`
+	Metric            string `validate:"omitempty,lte=50" json:"metric"`
+	Owner             string `validate:"omitempty" json:"owner"`
+	RankingMode       string `validate:"omitempty,oneof=all best_per_algo best_per_algo_family best_per_compute_plan" json:"ranking_mode"`
+	Tag               string `validate:"omitempty,lte=64" json:"tag"`
+	// TestDatasetVersion defaults to the current version of the objective test dataset
+	TestDatasetVersion int `validate:"omitempty,gte=1" json:"test_dataset_version"`
 }
 
 type inputPermissions struct {
 	Process inputPermission `validate:"required" json:"process"`
+	// Download defaults to the process permission
+	Download *inputPermission `validate:"omitempty" json:"download"`
+}
+
+// inputUpdateAssetPermissions is the representation of input args to replace the permissions of an asset
+type inputUpdateAssetPermissions struct {
+	Key         string           `validate:"required,len=36" json:"key"`
+	Permissions inputPermissions `validate:"required" json:"permissions"`
+}
+
+// inputCheckDownloadPermission is the representation of input args to check if a node can download an asset
+type inputCheckDownloadPermission struct {
+	Key    string `validate:"required,len=36" json:"key"`
+	NodeID string `validate:"required" json:"node_id"`
 }
 
 type inputPermission struct {
-	Public        bool     `json:"public,required"`
-	AuthorizedIDs []string `validate:"required" json:"authorized_ids"`
+	Public           bool     `json:"public,required"`
+	AuthorizedIDs    []string `validate:"required" json:"authorized_ids"`
+	AuthorizedGroups []string `validate:"omitempty,unique,dive,len=36" json:"authorized_groups"`
+}
+
+// inputAccessControl is the representation of input args to update the access control policy
+type inputAccessControl struct {
+	Roles map[string][]string `validate:"required,dive,keys,required,endkeys,required,dive,required" json:"roles"`
+}
+
+// inputNode is the representation of the optional input args to register or update a node
+type inputNode struct {
+	Name         string                `validate:"lte=100" json:"name"`
+	Contact      string                `validate:"lte=100" json:"contact"`
+	Capabilities inputNodeCapabilities `json:"capabilities"`
+	Metadata     map[string]string     `validate:"dive,keys,lte=50,endkeys,lte=100" json:"metadata"`
+}
+
+type inputNodeCapabilities struct {
+	CPUOnly          bool     `json:"cpu_only"`
+	MaxMemory        int      `validate:"gte=0" json:"max_memory"`
+	DataManagerTypes []string `validate:"omitempty,unique,dive,lte=30" json:"data_manager_types"`
+}
+
+// inputNodeQuotas is the representation of input args to set the quotas of a node
+type inputNodeQuotas struct {
+	MaxPendingTuples           int `validate:"gte=0" json:"max_pending_tuples"`
+	MaxPendingTuplesPerCreator int `validate:"gte=0" json:"max_pending_tuples_per_creator"`
+	MaxDailyTuples             int `validate:"gte=0" json:"max_daily_tuples"`
+	MaxDailyTuplesPerCreator   int `validate:"gte=0" json:"max_daily_tuples_per_creator"`
+}
+
+// inputNodeID is the representation of input args to query a node
+type inputNodeID struct {
+	NodeID string `validate:"required" json:"node_id"`
+}
+
+// inputUpdateNodeStatus is the representation of input args to change the status of a node
+type inputUpdateNodeStatus struct {
+	NodeID string `validate:"required" json:"node_id"`
+	Status string `validate:"required,oneof=active suspended removed" json:"status"`
+}
+
+// inputQueryNodes is the representation of the optional filters of queryNodes
+type inputQueryNodes struct {
+	Status          string `validate:"omitempty,oneof=active suspended removed" json:"status"`
+	CPUOnly         *bool  `json:"cpu_only"`
+	DataManagerType string `json:"data_manager_type"`
+}
+
+// inputWorkerSelector describes the capabilities an aggregatetuple worker must offer
+// when the worker is not named explicitly
+type inputWorkerSelector struct {
+	CPUOnly         *bool  `json:"cpu_only"`
+	MinMemory       int    `validate:"gte=0" json:"min_memory"`
+	DataManagerType string `json:"data_manager_type"`
+}
+
+// inputProposal is the representation of input args to submit an action to the consortium
+type inputProposal struct {
+	Key         string `validate:"required,len=36" json:"key"`
+	Description string `validate:"lte=1000" json:"description"`
+	Action      string `validate:"required,oneof=updateAccessControl updateChannelConfig updateNodeStatus updateObjectiveTestDataset" json:"action"`
+	Args        string `validate:"required" json:"args"`
+	Quorum      int    `validate:"gte=0" json:"quorum"`
+}
+
+// inputSecureAggregation is the representation of input args to start a secure aggregation on an aggregatetuple
+type inputSecureAggregation struct {
+	Key               string `validate:"required,len=36" json:"key"`
+	AggregatetupleKey string `validate:"required,len=36" json:"aggregatetuple_key"`
+	Threshold         int    `validate:"gte=0" json:"threshold"`
+}
+
+// inputSecureAggregationKey is the representation of input args to advertise a participant's public key
+type inputSecureAggregationKey struct {
+	Key       string `validate:"required,len=36" json:"key"`
+	PublicKey string `validate:"required,lte=2000" json:"public_key"`
+}
+
+// inputSecureAggregationShares is the representation of input args to submit shares, by node ID
+type inputSecureAggregationShares struct {
+	Key    string            `validate:"required,len=36" json:"key"`
+	Shares map[string]string `validate:"required,dive,keys,required,endkeys,required,lte=2000" json:"shares"`
+}
+
+// inputSecureAggregationCommitment is the representation of input args to commit a participant's mask
+type inputSecureAggregationCommitment struct {
+	Key        string `validate:"required,len=36" json:"key"`
+	TupleKey   string `validate:"required,len=36" json:"tuple_key"`
+	Commitment string `validate:"required,lte=2000" json:"commitment"`
+}
+
+// inputVoteProposal is the representation of input args to vote on a proposal
+type inputVoteProposal struct {
+	Key     string `validate:"required,len=36" json:"key"`
+	Approve bool   `json:"approve"`
+}
+
+// inputChannelConfig is the representation of input args to update the channel configuration
+type inputChannelConfig struct {
+	PageSize                int32    `validate:"required,gte=10,lte=10000" json:"page_size"`
+	MaxComputePlanSize      int      `validate:"gte=0" json:"max_compute_plan_size"`
+	MaxLogLength            int      `validate:"required,gte=1,lte=100000" json:"max_log_length"`
+	LogRetention            int      `validate:"gte=0" json:"log_retention"`
+	MaxMetadataItems        int      `validate:"required,gte=1,lte=1000" json:"max_metadata_items"`
+	AllowedDataManagerTypes []string `validate:"omitempty,unique,dive,lte=30" json:"allowed_data_manager_types"`
+}
+
+// inputNodeGroup is the representation of input args to register a node group
+type inputNodeGroup struct {
+	Key     string   `validate:"required,len=36" json:"key"`
+	Name    string   `validate:"required,gte=1,lte=100" json:"name"`
+	NodeIDs []string `validate:"required,unique" json:"node_ids"`
+}
+
+// inputUpdateNodeGroup is the representation of input args to replace the members of a node group
+type inputUpdateNodeGroup struct {
+	Key     string   `validate:"required,len=36" json:"key"`
+	NodeIDs []string `validate:"required,unique" json:"node_ids"`
 }
@@ -20,10 +20,15 @@ type inputAggregatetuple struct {
 	AlgoKey        string            `validate:"required,len=36" json:"algo_key"`
 	InModels       []string          `validate:"omitempty,dive,len=36" json:"in_models"`
 	ComputePlanKey string            `validate:"required_with=Rank" json:"compute_plan_key"`
-	Metadata       map[string]string `validate:"lte=100,dive,keys,lte=50,endkeys,lte=100" json:"metadata"`
+	Metadata       map[string]string `validate:"dive,keys,lte=50,endkeys,lte=100" json:"metadata"`
 	Rank           string            `json:"rank"`
 	Tag            string            `validate:"omitempty,lte=64" json:"tag"`
-	Worker         string            `validate:"required" json:"worker"`
+	Worker         string            `validate:"required_without=WorkerSelector" json:"worker"`
+
+	WorkerSelector *inputWorkerSelector `json:"worker_selector"`
+
+	OutModelPermissionsRule string            `validate:"omitempty,oneof=union intersection override" json:"out_model_permissions_rule"`
+	OutModelPermissions     *inputPermissions `json:"out_model_permissions"`
 }
 
 type inputAggregateAlgo struct {
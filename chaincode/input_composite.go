@@ -26,7 +26,7 @@ type inputCompositeTraintuple struct {
 	ComputePlanKey           string            `validate:"required_with=Rank" json:"compute_plan_key"`
 	Rank                     string            `json:"rank"`
 	Tag                      string            `validate:"omitempty,lte=64" json:"tag"`
-	Metadata                 map[string]string `validate:"lte=100,dive,keys,lte=50,endkeys,lte=100" json:"metadata"`
+	Metadata                 map[string]string `validate:"dive,keys,lte=50,endkeys,lte=100" json:"metadata"`
 }
 
 type inputCompositeAlgo struct {
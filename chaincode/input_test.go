@@ -324,8 +324,9 @@ func (success *inputLogSuccessTest) createDefault() [][]byte {
 	if success.Log == "" {
 		success.Log = "no error, ah ah ah"
 	}
-	if success.Perf == 0 {
-		success.Perf = 0.9
+	if success.Perf == nil {
+		perf := float32(0.9)
+		success.Perf = &perf
 	}
 
 	args := append([][]byte{[]byte("logSuccessTest")}, assetToJSON(success))
@@ -34,6 +34,9 @@ const (
 	AggregatetupleType
 	TesttupleType
 	ComputePlanType
+	NodeGroupType
+	ProposalType
+	SecureAggregationType
 	// when adding a new type here, don't forget to update
 	// the String() function in utils.go
 )
@@ -41,6 +44,7 @@ const (
 // StatusUpdater is exported
 type StatusUpdater interface {
 	commitStatusUpdate(db *LedgerDB, key string, status string) error
+	saveStatusUpdate(db *LedgerDB, key string, status string) error
 }
 
 // Objective is the representation of one of the element type stored in the ledger
@@ -50,10 +54,27 @@ type Objective struct {
 	AssetType   AssetType            `json:"asset_type"`
 	Description *ChecksumAddress     `json:"description"`
 	Metrics     *ChecksumAddressName `json:"metrics"`
+	MetricNames []string             `json:"metric_names"`
 	Owner       string               `json:"owner"`
 	TestDataset *Dataset             `json:"test_dataset"`
 	Permissions Permissions          `json:"permissions"`
 	Metadata    map[string]string    `json:"metadata"`
+	// TestDatasetVersion starts at 1 and is incremented each time the test dataset is replaced
+	TestDatasetVersion   int        `json:"test_dataset_version"`
+	PreviousTestDatasets []*Dataset `json:"previous_test_datasets"`
+	Challenge            *Challenge `json:"challenge"`
+	// Blind objectives hide the scores of certified testtuples to everyone
+	// but their owner until the scores are revealed
+	Blind          bool `json:"blind"`
+	ScoresRevealed bool `json:"scores_revealed"`
+}
+
+// Challenge limits the submission of certified testtuples on an objective.
+// Zero values mean no limit.
+type Challenge struct {
+	OpeningTimestamp              int64 `json:"opening_timestamp"`
+	ClosingTimestamp              int64 `json:"closing_timestamp"`
+	MaxCertifiedTesttuplesPerNode int   `json:"max_certified_testtuples_per_node"`
 }
 
 // DataManager is the representation of one of the elements type stored in the ledger
@@ -68,6 +89,19 @@ type DataManager struct {
 	ObjectiveKey string            `json:"objective_key"`
 	Permissions  Permissions       `json:"permissions"`
 	Metadata     map[string]string `json:"metadata"`
+
+	UsagePolicy *UsagePolicy `json:"usage_policy"`
+}
+
+// UsagePolicy restricts how the data of a data manager can be used, beyond
+// who can process it
+type UsagePolicy struct {
+	AllowedAlgoOwners       []string `json:"allowed_algo_owners"`
+	AllowedAlgoKeys         []string `json:"allowed_algo_keys"`
+	MinSamplesPerTraintuple int      `json:"min_samples_per_traintuple"`
+	ForbidTestOnTrainData   bool     `json:"forbid_test_on_train_data"`
+	PrivateModels           bool     `json:"private_models"`
+	MinAggregationWorkers   int      `json:"min_aggregation_workers"`
 }
 
 // DataSample is the representation of one of the element type stored in the ledger
@@ -75,6 +109,7 @@ type DataSample struct {
 	AssetType       AssetType `json:"asset_type"`
 	DataManagerKeys []string  `json:"data_manager_keys"`
 	Owner           string    `json:"owner"`
+	Revoked         bool      `json:"revoked"`
 	TestOnly        bool      `json:"testOnly"`
 }
 
@@ -89,6 +124,12 @@ type Algo struct {
 	Owner          string            `json:"owner"`
 	Permissions    Permissions       `json:"permissions"`
 	Metadata       map[string]string `json:"metadata"`
+	Status         string            `json:"status"`
+	StatusReason   string            `json:"status_reason"`
+	ReplacementKey string            `json:"replacement_key"`
+	ParentKey      string            `json:"parent_key"`
+	FamilyKey      string            `json:"family_key"`
+	Version        int               `json:"version"`
 }
 
 // CompositeAlgo is the representation of one of the element type stored in the ledger
@@ -114,6 +155,9 @@ type GenericTuple struct {
 	Rank           int               `json:"rank"`
 	Status         string            `json:"status"`
 	Tag            string            `json:"tag"`
+
+	Dataset *Dataset `json:"dataset"` // set for traintuples and composite traintuples
+	Worker  string   `json:"worker"`  // set for aggregatetuples
 }
 
 // Traintuple is the representation of one the element type stored in the ledger. It describes a training task occuring on the platform
@@ -167,8 +211,14 @@ type Aggregatetuple struct {
 	Tag            string              `json:"tag"`
 	InModelKeys    []string            `json:"in_models"`
 	OutModel       *KeyChecksumAddress `json:"out_model"`
-	Permissions    Permissions         `json:"permissions"` // TODO (aggregate): what do permissions mean here?
+	Permissions    Permissions         `json:"permissions"`
 	Worker         string              `json:"worker"`
+
+	// PermissionsRule tells how Permissions were derived from the in-models' permissions
+	PermissionsRule string `json:"permissions_rule"`
+	// SecureAggregationKey is the key of the secure aggregation protocol run
+	// by the in-models' workers, if any
+	SecureAggregationKey string `json:"secure_aggregation_key"`
 }
 
 // CompositeTraintupleOutModel is the out-model of a CompositeTraintuple
@@ -192,6 +242,7 @@ type Testtuple struct {
 	ComputePlanKey string            `json:"compute_plan_key"`
 	Creator        string            `json:"creator"`
 	Dataset        *TtDataset        `json:"dataset"`
+	DoneTimestamp  int64             `json:"done_timestamp"`
 	Log            string            `json:"log"`
 	Metadata       map[string]string `json:"metadata"`
 	TraintupleKey  string            `json:"traintuple_key"`
@@ -200,6 +251,8 @@ type Testtuple struct {
 	Rank           int               `json:"rank"`
 	Status         string            `json:"status"`
 	Tag            string            `json:"tag"`
+	// TestDatasetVersion is the version of the objective test dataset a certified testtuple runs on
+	TestDatasetVersion int `json:"test_dataset_version"`
 }
 
 // ComputePlan is the ledger's representation of a compute plan.
@@ -217,6 +270,8 @@ type ComputePlan struct {
 	TesttupleKeys           []string             `json:"testtuple_keys"`
 	TraintupleKeys          []string             `json:"traintuple_keys"`
 	Workers                 []string             `json:"workers"`
+
+	MinAggregationWorkers int `json:"min_aggregation_workers"`
 }
 
 // ComputePlanState is the ledger's representation of the compute plan state.
@@ -300,11 +355,12 @@ type Dataset struct {
 
 // TtDataset stores info about dataset in a Traintyple (train or test data) and in a PredTuple (later)
 type TtDataset struct {
-	Key            string   `json:"key"`
-	Worker         string   `json:"worker"`
-	DataSampleKeys []string `json:"data_sample_keys"`
-	OpenerChecksum string   `json:"opener_checksum"`
-	Perf           float32  `json:"perf"`
+	Key            string             `json:"key"`
+	Worker         string             `json:"worker"`
+	DataSampleKeys []string           `json:"data_sample_keys"`
+	OpenerChecksum string             `json:"opener_checksum"`
+	Perf           float32            `json:"perf"`
+	Perfs          map[string]float32 `json:"perfs"`
 }
 
 // TtObjective stores info about a objective in a Traintuple
@@ -317,4 +373,115 @@ type TtObjective struct {
 // would be used to list authorized nodes for permissions
 type Node struct {
 	ID string `json:"id"`
+
+	Name         string            `json:"name"`
+	Contact      string            `json:"contact"`
+	Capabilities NodeCapabilities  `json:"capabilities"`
+	Metadata     map[string]string `json:"metadata"`
+	Status       string            `json:"status"`
+	Quotas       NodeQuotas        `json:"quotas"`
+}
+
+// NodeQuotas limits the tuples other nodes can create on a worker. A zero value
+// means no limit. Pending tuples are the todo and doing ones.
+type NodeQuotas struct {
+	MaxPendingTuples           int `json:"max_pending_tuples"`
+	MaxPendingTuplesPerCreator int `json:"max_pending_tuples_per_creator"`
+	MaxDailyTuples             int `json:"max_daily_tuples"`
+	MaxDailyTuplesPerCreator   int `json:"max_daily_tuples_per_creator"`
+}
+
+// WorkerCounters keeps track of the tuples of a worker to enforce its quotas
+type WorkerCounters struct {
+	Worker            string         `json:"worker"`
+	Pending           int            `json:"pending"`
+	PendingPerCreator map[string]int `json:"pending_per_creator"`
+	Day               int64          `json:"day"`
+	Created           int            `json:"created"`
+	CreatedPerCreator map[string]int `json:"created_per_creator"`
+}
+
+// NodeCapabilities describes the resources a node offers to compute tuples
+type NodeCapabilities struct {
+	CPUOnly          bool     `json:"cpu_only"`
+	MaxMemory        int      `json:"max_memory"`
+	DataManagerTypes []string `json:"data_manager_types"`
+}
+
+// Proposal is an action submitted to the vote of the consortium. It runs the
+// contract Action with Args once Quorum nodes have approved it.
+type Proposal struct {
+	Key                string         `json:"key"`
+	AssetType          AssetType      `json:"asset_type"`
+	Proposer           string         `json:"proposer"`
+	Description        string         `json:"description"`
+	Action             string         `json:"action"`
+	Args               string         `json:"args"`
+	Quorum             int            `json:"quorum"`
+	Votes              []ProposalVote `json:"votes"`
+	Status             string         `json:"status"`
+	CreationTimestamp  int64          `json:"creation_timestamp"`
+	ExecutionTimestamp int64          `json:"execution_timestamp"`
+	ExecutedBy         string         `json:"executed_by"`
+}
+
+// ProposalVote is the vote of a node on a proposal
+type ProposalVote struct {
+	NodeID    string `json:"node_id"`
+	Approve   bool   `json:"approve"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// SecureAggregation coordinates, through the ledger, the rounds of the secure
+// aggregation protocol run by the workers of an aggregatetuple's in-models, so
+// that the aggregator can only unmask the sum of their masked updates.
+// Participants which don't complete a phase drop out of the protocol, which
+// aborts if less than Threshold participants remain.
+type SecureAggregation struct {
+	Key               string    `json:"key"`
+	AssetType         AssetType `json:"asset_type"`
+	AggregatetupleKey string    `json:"aggregatetuple_key"`
+	Aggregator        string    `json:"aggregator"`
+	Participants      []string  `json:"participants"`
+	Active            []string  `json:"active"`
+	Threshold         int       `json:"threshold"`
+	Phase             string    `json:"phase"`
+	// PublicKeys holds the public key advertised by each participant
+	PublicKeys map[string]string `json:"public_keys"`
+	// EncryptedShares holds, for each participant, the shares of its secrets
+	// encrypted for every other participant
+	EncryptedShares map[string]map[string]string `json:"encrypted_shares"`
+	// Commitments holds the commitment of each participant's mask, attached
+	// to the out-model of its in-model tuple
+	Commitments map[string]SecureAggregationCommitment `json:"commitments"`
+	// RecoveryShares holds, for each participant, the shares it reveals about
+	// the other participants: of the self mask of survivors and of the secret
+	// key of the dropped ones
+	RecoveryShares map[string]map[string]string `json:"recovery_shares"`
+}
+
+// SecureAggregationCommitment is the commitment of a participant's mask
+type SecureAggregationCommitment struct {
+	TupleKey   string `json:"tuple_key"`
+	Commitment string `json:"commitment"`
+}
+
+// ChannelConfig holds the limits of the channel which can be tuned without
+// redeploying the chaincode
+type ChannelConfig struct {
+	PageSize                int32    `json:"page_size"`
+	MaxComputePlanSize      int      `json:"max_compute_plan_size"`
+	MaxLogLength            int      `json:"max_log_length"`
+	LogRetention            int      `json:"log_retention"`
+	MaxMetadataItems        int      `json:"max_metadata_items"`
+	AllowedDataManagerTypes []string `json:"allowed_data_manager_types"`
+}
+
+// NodeGroup is a named list of nodes which can be authorized at once in permissions
+type NodeGroup struct {
+	Key       string    `json:"key"`
+	AssetType AssetType `json:"asset_type"`
+	Name      string    `json:"name"`
+	Owner     string    `json:"owner"`
+	NodeIDs   []string  `json:"node_ids"`
 }
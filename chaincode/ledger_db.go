@@ -17,15 +17,23 @@ package main
 import (
 	"chaincode/errors"
 	"encoding/json"
+	"sort"
 	"strings"
 	"sync"
+	"unicode/utf8"
 
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 )
 
+// maxUnicodeRune is the upper bound of the composite keys sharing a prefix
+const maxUnicodeRune = string(utf8.MaxRune)
+
 // State is a in-memory representation of the db state
 type State struct {
 	items map[string]([]byte)
+	// writes holds the pending writes of the transaction, flushed once at
+	// the end of it. A nil value is a pending deletion.
+	writes map[string]([]byte)
 }
 
 // LedgerDB to access the chaincode database during the lifetime of a SmartContract
@@ -34,6 +42,11 @@ type LedgerDB struct {
 	event            *Event
 	transactionState State
 	mutex            *sync.RWMutex
+
+	// proposalKey is the key of the governance proposal being executed, if any
+	proposalKey string
+	// config holds the channel configuration, loaded once per transaction
+	config ChannelConfig
 }
 
 // NewLedgerDB create a new db to access the chaincode during a SmartContract
@@ -41,9 +54,11 @@ func NewLedgerDB(stub shim.ChaincodeStubInterface) *LedgerDB {
 	return &LedgerDB{
 		cc: stub,
 		transactionState: State{
-			items: make(map[string]([]byte)),
+			items:  make(map[string]([]byte)),
+			writes: make(map[string]([]byte)),
 		},
-		mutex: &sync.RWMutex{},
+		mutex:  &sync.RWMutex{},
+		config: defaultChannelConfig(),
 	}
 }
 
@@ -71,6 +86,56 @@ func (db *LedgerDB) putTransactionState(key string, state []byte) {
 	db.transactionState.items[key] = state
 }
 
+// getPendingWrite returns the pending write of a key, if any. A nil state
+// is a pending deletion.
+func (db *LedgerDB) getPendingWrite(key string) ([]byte, bool) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	state, ok := db.transactionState.writes[key]
+	return state, ok
+}
+
+// putPendingWrite buffers the write of a key until the end of the transaction.
+// A nil state deletes the key.
+func (db *LedgerDB) putPendingWrite(key string, state []byte) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.transactionState.writes[key] = state
+}
+
+// cancelPendingWrite drops the pending write of a key
+func (db *LedgerDB) cancelPendingWrite(key string) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	delete(db.transactionState.writes, key)
+}
+
+// Flush writes the pending writes of the transaction to the chaincode db,
+// each key once, in a deterministic order
+func (db *LedgerDB) Flush() error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	keys := make([]string, 0, len(db.transactionState.writes))
+	for key := range db.transactionState.writes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		state := db.transactionState.writes[key]
+		var err error
+		if state == nil {
+			err = db.cc.DelState(key)
+		} else {
+			err = db.cc.PutState(key, state)
+		}
+		if err != nil {
+			return errors.Internal("cannot write key %s: %s", key, err.Error())
+		}
+	}
+	db.transactionState.writes = make(map[string]([]byte))
+	return nil
+}
+
 // Get retrieves an object stored in the chaincode db and set the input object value
 func (db *LedgerDB) Get(key string, object interface{}) error {
 	var buff []byte
@@ -88,23 +153,28 @@ func (db *LedgerDB) Get(key string, object interface{}) error {
 	return json.Unmarshal(buff, &object)
 }
 
-// KeyExists checks if a key is stored in the chaincode db
+// KeyExists checks if a key is stored in the chaincode db, or pending to be
 func (db *LedgerDB) KeyExists(key string) (bool, error) {
+	if state, ok := db.getPendingWrite(key); ok {
+		return state != nil, nil
+	}
 	buff, err := db.cc.GetState(key)
 	return buff != nil, err
 }
 
-// Put stores an object in the chaincode db, if the object already exists it is replaced
+// Put stores an object in the chaincode db, if the object already exists it is replaced.
+// The write is buffered until the end of the transaction, so that an object updated
+// several times is only written once.
 func (db *LedgerDB) Put(key string, object interface{}) error {
-	buff, _ := json.Marshal(object)
-
-	if err := db.cc.PutState(key, buff); err != nil {
-		return err
+	buff, err := json.Marshal(object)
+	if err != nil {
+		return errors.Internal("cannot marshal object with key %s: %s", key, err.Error())
 	}
 	// TransactionState is updated to ensure that even if the data is not committed, a further
 	// call to get this struct will returned the updated one (and not the original one).
 	// This is currently required when setting the statuses of the traintuple children.
 	db.putTransactionState(key, buff)
+	db.putPendingWrite(key, buff)
 
 	return nil
 }
@@ -131,20 +201,30 @@ func (db *LedgerDB) CreateIndex(index string, attributes []string) error {
 	if err != nil {
 		return errors.Internal("cannot create index %s: %s", index, err.Error())
 	}
-	value := []byte{0x00}
-	if err = db.cc.PutState(compositeKey, value); err != nil {
-		return errors.Internal("cannot create index %s: %s", index, err.Error())
-	}
+	db.putPendingWrite(compositeKey, []byte{0x00})
 	return nil
 }
 
-// DeleteIndex deletes a composite key in the chaincode db
+// DeleteIndex deletes a composite key in the chaincode db. Deleting an index
+// created during the transaction cancels its creation.
 func (db *LedgerDB) DeleteIndex(index string, attributes []string) error {
 	compositeKey, err := db.cc.CreateCompositeKey(index, attributes)
 	if err != nil {
 		return err
 	}
-	return db.cc.DelState(compositeKey)
+	if state, ok := db.getPendingWrite(compositeKey); ok && state != nil {
+		// the chaincode db still holds the state from before the transaction
+		committed, err := db.cc.GetState(compositeKey)
+		if err != nil {
+			return err
+		}
+		if committed == nil {
+			db.cancelPendingWrite(compositeKey)
+			return nil
+		}
+	}
+	db.putPendingWrite(compositeKey, nil)
+	return nil
 }
 
 // UpdateIndex updates an existing composite key in the chaincode db
@@ -155,9 +235,14 @@ func (db *LedgerDB) UpdateIndex(index string, oldAttributes []string, newAttribu
 	return db.CreateIndex(index, newAttribues)
 }
 
-// GetIndexKeys returns keys matching composite key values from the chaincode db
+// GetIndexKeys returns keys matching composite key values from the chaincode db,
+// including the indexes created or deleted during the transaction
 func (db *LedgerDB) GetIndexKeys(index string, attributes []string) ([]string, error) {
-	keys := make([]string, 0)
+	partialKey, err := db.cc.CreateCompositeKey(index, attributes)
+	if err != nil {
+		return nil, errors.Internal("get index %s failed: %s", index, err.Error())
+	}
+	compositeKeys := make([]string, 0)
 	iterator, err := db.cc.GetStateByPartialCompositeKey(index, attributes)
 	if err != nil {
 		return nil, errors.Internal("get index %s failed: %s", index, err.Error())
@@ -168,18 +253,19 @@ func (db *LedgerDB) GetIndexKeys(index string, attributes []string) ([]string, e
 		if err != nil {
 			return nil, err
 		}
-		_, keyParts, err := db.cc.SplitCompositeKey(compositeKey.Key)
-		if err != nil {
-			return nil, errors.Internal("get index %s failed: cannot split key %s: %s", index, compositeKey.Key, err.Error())
-		}
-		keys = append(keys, keyParts[len(keyParts)-1])
+		compositeKeys = append(compositeKeys, compositeKey.Key)
 	}
-	return keys, nil
+	compositeKeys = db.mergePendingIndexes(compositeKeys, partialKey, partialKey+maxUnicodeRune)
+	return db.splitIndexKeys(index, compositeKeys)
 }
 
-// GetIndexKeysWithPagination returns keys matching composite key values from the chaincode db
+// GetIndexKeysWithPagination returns keys matching composite key values from the chaincode db,
+// including the indexes created or deleted during the transaction within the returned page
 func (db *LedgerDB) GetIndexKeysWithPagination(index string, attributes []string, pageSize int32, bookmark string) ([]string, string, error) {
-	keys := make([]string, 0)
+	partialKey, err := db.cc.CreateCompositeKey(index, attributes)
+	if err != nil {
+		return nil, "", errors.Internal("get index %s failed: %s", index, err.Error())
+	}
 
 	if bookmark != "" {
 		// Transform bookmark from JSON-friendly format to CouchDB format
@@ -188,6 +274,7 @@ func (db *LedgerDB) GetIndexKeysWithPagination(index string, attributes []string
 		bookmark = strings.Replace(bookmark, "END", "\U0010ffff", -1)
 	}
 
+	compositeKeys := make([]string, 0)
 	iterator, metadata, err := db.cc.GetStateByPartialCompositeKeyWithPagination(index, attributes, pageSize, bookmark)
 	if err != nil {
 		return nil, "", errors.Internal("get index %s failed: %s", index, err.Error())
@@ -198,16 +285,37 @@ func (db *LedgerDB) GetIndexKeysWithPagination(index string, attributes []string
 		if err != nil {
 			return nil, "", err
 		}
-		_, keyParts, err := db.cc.SplitCompositeKey(compositeKey.Key)
-		if err != nil {
-			return nil, "", errors.Internal("get index %s failed: cannot split key %s: %s", index, compositeKey.Key, err.Error())
-		}
-		keys = append(keys, keyParts[len(keyParts)-1])
+		compositeKeys = append(compositeKeys, compositeKey.Key)
 	}
 
+	// The page spans from the bookmark to the next one, the pending indexes
+	// in that range belong to it
+	start, end := partialKey, partialKey+maxUnicodeRune
+	if bookmark > start {
+		start = bookmark
+	}
+	if metadata != nil && metadata.Bookmark != "" && metadata.Bookmark < end {
+		end = metadata.Bookmark
+	}
+	compositeKeys = db.mergePendingIndexes(compositeKeys, start, end)
+	nextBookmark := ""
 	if metadata != nil {
+		nextBookmark = metadata.Bookmark
+	}
+	// The pending indexes can overflow the page, the next one starts at the
+	// first key left out
+	if pageSize > 0 && int32(len(compositeKeys)) > pageSize {
+		nextBookmark = compositeKeys[pageSize]
+		compositeKeys = compositeKeys[:pageSize]
+	}
+	keys, err := db.splitIndexKeys(index, compositeKeys)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if metadata != nil || nextBookmark != "" {
 		// Transform bookmark from CouchDB format to JSON-friendly format
-		bookmark = strings.Replace(metadata.Bookmark, "\x00", "/", -1)
+		bookmark = strings.Replace(nextBookmark, "\x00", "/", -1)
 		bookmark = strings.Replace(bookmark, "\\u0000", "#", -1)
 		bookmark = strings.Replace(bookmark, "\U0010ffff", "END", -1)
 	}
@@ -215,6 +323,53 @@ func (db *LedgerDB) GetIndexKeysWithPagination(index string, attributes []string
 	return keys, bookmark, nil
 }
 
+// mergePendingIndexes removes the composite keys deleted during the transaction
+// and adds the ones created during the transaction within [start, end)
+func (db *LedgerDB) mergePendingIndexes(compositeKeys []string, start string, end string) []string {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	merged := make([]string, 0, len(compositeKeys))
+	committed := make(map[string]bool, len(compositeKeys))
+	for _, key := range compositeKeys {
+		committed[key] = true
+		if state, ok := db.transactionState.writes[key]; ok && state == nil {
+			continue
+		}
+		merged = append(merged, key)
+	}
+	for key, state := range db.transactionState.writes {
+		if state == nil || committed[key] || key < start || key >= end {
+			continue
+		}
+		merged = append(merged, key)
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+// splitIndexKeys returns the last attribute of each composite key, which is the
+// key of the indexed asset
+func (db *LedgerDB) splitIndexKeys(index string, compositeKeys []string) ([]string, error) {
+	keys := make([]string, 0, len(compositeKeys))
+	for _, compositeKey := range compositeKeys {
+		_, keyParts, err := db.cc.SplitCompositeKey(compositeKey)
+		if err != nil {
+			return nil, errors.Internal("get index %s failed: cannot split key %s: %s", index, compositeKey, err.Error())
+		}
+		keys = append(keys, keyParts[len(keyParts)-1])
+	}
+	return keys, nil
+}
+
+// GetTxTimestamp returns the timestamp of the current transaction, in seconds since the epoch
+func (db *LedgerDB) GetTxTimestamp() (int64, error) {
+	timestamp, err := db.cc.GetTxTimestamp()
+	if err != nil {
+		return 0, errors.Internal("cannot get transaction timestamp: %s", err.Error())
+	}
+	return timestamp.GetSeconds(), nil
+}
+
 // ----------------------------------------------
 // High-level functions
 // ----------------------------------------------
@@ -292,6 +447,19 @@ func (db *LedgerDB) GetAlgo(key string) (Algo, error) {
 	return algo, nil
 }
 
+// GetAlgoOfAnyType fetches an Algo, a CompositeAlgo or an AggregateAlgo from
+// the ledger using its unique key, as they share the same representation
+func (db *LedgerDB) GetAlgoOfAnyType(key string) (Algo, error) {
+	algo := Algo{}
+	if err := db.Get(key, &algo); err != nil {
+		return algo, err
+	}
+	if !isAlgoType(algo.AssetType) {
+		return algo, errors.NotFound("algo %s not found", key)
+	}
+	return algo, nil
+}
+
 // GetCompositeAlgo fetches a CompositeAlgo from the ledger using its unique key
 func (db *LedgerDB) GetCompositeAlgo(key string) (CompositeAlgo, error) {
 	algo := CompositeAlgo{}
@@ -488,6 +656,42 @@ func (db *LedgerDB) GetNode(key string) (Node, error) {
 	return node, nil
 }
 
+// GetProposal fetches a governance Proposal from the ledger based on its unique key
+func (db *LedgerDB) GetProposal(key string) (Proposal, error) {
+	proposal := Proposal{}
+	if err := db.Get(key, &proposal); err != nil {
+		return proposal, err
+	}
+	if proposal.AssetType != ProposalType {
+		return proposal, errors.NotFound("proposal %s not found", key)
+	}
+	return proposal, nil
+}
+
+// GetSecureAggregation fetches a SecureAggregation from the ledger based on its unique key
+func (db *LedgerDB) GetSecureAggregation(key string) (SecureAggregation, error) {
+	secureAggregation := SecureAggregation{}
+	if err := db.Get(key, &secureAggregation); err != nil {
+		return secureAggregation, err
+	}
+	if secureAggregation.AssetType != SecureAggregationType {
+		return secureAggregation, errors.NotFound("secure aggregation %s not found", key)
+	}
+	return secureAggregation, nil
+}
+
+// GetNodeGroup fetches a NodeGroup from the ledger based on its unique key
+func (db *LedgerDB) GetNodeGroup(key string) (NodeGroup, error) {
+	group := NodeGroup{}
+	if err := db.Get(key, &group); err != nil {
+		return group, err
+	}
+	if group.AssetType != NodeGroupType {
+		return group, errors.NotFound("node group %s not found", key)
+	}
+	return group, nil
+}
+
 // ----------------------------------------------
 // High-level functions for events
 // ----------------------------------------------
@@ -521,10 +725,16 @@ func (db *LedgerDB) AddTupleEvent(tupleKey string) error {
 	if genericTuple.Status != StatusTodo {
 		return nil
 	}
+	return db.addTupleEvent(genericTuple.AssetType, tupleKey)
+}
+
+// addTupleEvent adds the output tuple matching the tupleKey to the event
+// struct, whatever its status
+func (db *LedgerDB) addTupleEvent(assetType AssetType, tupleKey string) error {
 	if db.event == nil {
 		db.event = &Event{}
 	}
-	switch genericTuple.AssetType {
+	switch assetType {
 	case TraintupleType:
 		tuple, err := db.GetTraintuple(tupleKey)
 		if err != nil {
@@ -555,12 +765,20 @@ func (db *LedgerDB) AddTupleEvent(tupleKey string) error {
 			return err
 		}
 		out := outputTesttuple{}
-		out.Fill(db, tuple)
+		out.FillForChannel(db, tuple)
 		db.event.Testtuples = append(db.event.Testtuples, out)
 	}
 	return nil
 }
 
+// AddPermissionsChangeEvent adds an update of the permissions of an asset to the event struct
+func (db *LedgerDB) AddPermissionsChangeEvent(change eventPermissionsChange) {
+	if db.event == nil {
+		db.event = &Event{}
+	}
+	db.event.PermissionsChanges = append(db.event.PermissionsChanges, change)
+}
+
 // AddComputePlanEvent add the compute plan matching the ID to the event struct
 func (db *LedgerDB) AddComputePlanEvent(ComputePlanKey, status string, ModelsToDelete []string) error {
 	if db.event == nil {
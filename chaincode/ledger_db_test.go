@@ -18,6 +18,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetOutModelKeyChecksumAddress(t *testing.T) {
@@ -50,3 +51,83 @@ func TestGetOutModelKeyChecksumAddress(t *testing.T) {
 	_, err = db.GetOutModelKeyChecksumAddress(composite, []AssetType{TraintupleType})
 	assert.Error(t, err, "the composite traintuple should be found when requesting regular traintuples only")
 }
+
+func TestLedgerDBWriteBehind(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	mockStub.MockTransactionStart("42")
+	db := NewLedgerDB(mockStub)
+	index := "tuple~worker~status~key"
+	indexKey := func(status string) string {
+		key, err := mockStub.CreateCompositeKey(index, []string{"tuple", workerA, status, "key"})
+		require.NoError(t, err)
+		return key
+	}
+	require.NoError(t, db.CreateIndex(index, []string{"tuple", workerA, StatusWaiting, "key"}))
+	require.NoError(t, db.Flush())
+	require.Contains(t, mockStub.State, indexKey(StatusWaiting))
+
+	// writes are buffered until the end of the transaction, but visible to reads
+	require.NoError(t, db.Put("key", map[string]string{"status": StatusTodo}))
+	require.NoError(t, db.Put("key", map[string]string{"status": StatusDoing}))
+	assert.NotContains(t, mockStub.State, "key")
+	exists, err := db.KeyExists("key")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Error(t, db.Add("key", map[string]string{}))
+	object := map[string]string{}
+	require.NoError(t, db.Get("key", &object))
+	assert.Equal(t, StatusDoing, object["status"])
+
+	// an index created then deleted during the transaction is never written
+	require.NoError(t, db.UpdateIndex(index, []string{"tuple", workerA, StatusWaiting, "key"}, []string{"tuple", workerA, StatusTodo, "key"}))
+	require.NoError(t, db.UpdateIndex(index, []string{"tuple", workerA, StatusTodo, "key"}, []string{"tuple", workerA, StatusDoing, "key"}))
+	assert.Len(t, db.transactionState.writes, 3, "the todo index is neither created nor deleted")
+
+	require.NoError(t, db.Flush())
+	assert.JSONEq(t, `{"status": "doing"}`, string(mockStub.State["key"]))
+	assert.NotContains(t, mockStub.State, indexKey(StatusWaiting))
+	assert.NotContains(t, mockStub.State, indexKey(StatusTodo))
+	assert.Contains(t, mockStub.State, indexKey(StatusDoing))
+	assert.Empty(t, db.transactionState.writes)
+}
+
+func TestLedgerDBIndexOverlay(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	mockStub.MockTransactionStart("42")
+	db := NewLedgerDB(mockStub)
+	index := "tuple~worker~status~key"
+	attributes := func(key string) []string {
+		return []string{"tuple", workerA, StatusWaiting, key}
+	}
+	for _, key := range []string{"key1", "key3", "key5"} {
+		require.NoError(t, db.CreateIndex(index, attributes(key)))
+	}
+	require.NoError(t, db.Flush())
+
+	// index reads see the indexes created and deleted during the transaction
+	for _, key := range []string{"key2", "key4", "key6"} {
+		require.NoError(t, db.CreateIndex(index, attributes(key)))
+	}
+	require.NoError(t, db.DeleteIndex(index, attributes("key3")))
+	expected := []string{"key1", "key2", "key4", "key5", "key6"}
+	keys, err := db.GetIndexKeys(index, []string{"tuple", workerA, StatusWaiting})
+	require.NoError(t, err)
+	assert.Equal(t, expected, keys)
+
+	// each pending index shows up in a single page, which never exceeds the page size
+	keys = []string{}
+	bookmark := ""
+	for {
+		page, nextBookmark, err := db.GetIndexKeysWithPagination(index, []string{"tuple", workerA, StatusWaiting}, 2, bookmark)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(page), 2)
+		keys = append(keys, page...)
+		if nextBookmark == "" {
+			break
+		}
+		bookmark = nextBookmark
+	}
+	assert.Equal(t, expected, keys)
+}
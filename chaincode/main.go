@@ -73,7 +73,17 @@ func (t *SubstraChaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Respons
 	hasBookmark := false
 	var bookmark string
 
+	if err = db.LoadChannelConfig(); err != nil {
+		return formatErrorResponse(err)
+	}
+	if err = checkAccessControl(db, fn); err != nil {
+		logger.Errorf("[%s][%s] Access denied: '%s'", stub.GetChannelID(), stub.GetTxID()[:10], err)
+		return formatErrorResponse(err)
+	}
+
 	switch fn {
+	case "checkDownloadPermission":
+		result, err = checkDownloadPermission(db, args)
 	case "createComputePlan":
 		result, err = createComputePlan(db, args)
 	case "createTesttuple":
@@ -86,6 +96,8 @@ func (t *SubstraChaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Respons
 		result, err = createAggregatetuple(db, args)
 	case "cancelComputePlan":
 		result, err = cancelComputePlan(db, args)
+	case "indexObjectiveLeaderboard":
+		result, err = indexObjectiveLeaderboard(db, args)
 	case "logFailTest":
 		result, err = logFailTest(db, args)
 	case "logFailTrain":
@@ -112,6 +124,8 @@ func (t *SubstraChaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Respons
 		result, err = logSuccessAggregate(db, args)
 	case "queryAlgo":
 		result, err = queryAlgo(db, args)
+	case "queryAlgoVersions":
+		result, err = queryAlgoVersions(db, args)
 	case "queryAlgos":
 		result, bookmark, err = queryAlgos(db, args)
 		hasBookmark = true
@@ -133,6 +147,9 @@ func (t *SubstraChaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Respons
 	case "queryDataSamples":
 		result, bookmark, err = queryDataSamples(db, args)
 		hasBookmark = true
+	case "queryDataSampleUsage":
+		result, bookmark, err = queryDataSampleUsage(db, args)
+		hasBookmark = true
 	case "queryDataset":
 		result, err = queryDataset(db, args)
 	case "queryFilter":
@@ -141,6 +158,9 @@ func (t *SubstraChaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Respons
 		result, err = queryModel(db, args)
 	case "queryModelDetails":
 		result, err = queryModelDetails(db, args)
+	case "queryModelProvenance":
+		result, bookmark, err = queryModelProvenance(db, args)
+		hasBookmark = true
 	case "queryModels":
 		result, bookmark, err = queryModels(db, args)
 		hasBookmark = true
@@ -151,6 +171,8 @@ func (t *SubstraChaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Respons
 	case "queryObjectives":
 		result, bookmark, err = queryObjectives(db, args)
 		hasBookmark = true
+	case "queryPermissionsHistory":
+		result, err = queryPermissionsHistory(db, args)
 	case "queryTesttuple":
 		result, err = queryTesttuple(db, args)
 	case "queryTesttuples":
@@ -188,16 +210,78 @@ func (t *SubstraChaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Respons
 		result, err = registerDataSample(db, args)
 	case "registerObjective":
 		result, err = registerObjective(db, args)
+	case "updateAlgoStatus":
+		result, err = updateAlgoStatus(db, args)
+	case "updateAssetPermissions":
+		result, err = updateAssetPermissions(db, args)
 	case "updateComputePlan":
 		result, err = updateComputePlan(db, args)
 	case "updateDataManager":
 		result, err = updateDataManager(db, args)
+	case "updateObjectiveTestDataset":
+		result, err = updateObjectiveTestDataset(db, args)
+	case "updateUsagePolicy":
+		result, err = updateUsagePolicy(db, args)
 	case "updateDataSample":
 		result, err = updateDataSample(db, args)
+	case "revealObjectiveScores":
+		result, err = revealObjectiveScores(db, args)
+	case "revokeDataSample":
+		result, err = revokeDataSample(db, args)
 	case "registerNode":
 		result, err = registerNode(db, args)
 	case "queryNodes":
 		result, err = queryNodes(db, args)
+	case "updateNode":
+		result, err = updateNode(db, args)
+	case "updateNodeQuotas":
+		result, err = updateNodeQuotas(db, args)
+	case "queryNodeQuotas":
+		result, err = queryNodeQuotas(db, args)
+	case "updateNodeStatus":
+		result, err = updateNodeStatus(db, args)
+	case "registerNodeGroup":
+		result, err = registerNodeGroup(db, args)
+	case "updateNodeGroup":
+		result, err = updateNodeGroup(db, args)
+	case "queryNodeGroup":
+		result, err = queryNodeGroup(db, args)
+	case "queryNodeGroups":
+		result, bookmark, err = queryNodeGroups(db, args)
+		hasBookmark = true
+	case "createProposal":
+		result, err = createProposal(db, args)
+	case "voteProposal":
+		result, err = voteProposal(db, args)
+	case "executeProposal":
+		result, err = executeProposal(db, args)
+	case "queryProposal":
+		result, err = queryProposal(db, args)
+	case "queryProposals":
+		result, bookmark, err = queryProposals(db, args)
+		hasBookmark = true
+	case "createSecureAggregation":
+		result, err = createSecureAggregation(db, args)
+	case "advertiseSecureAggregationKey":
+		result, err = advertiseSecureAggregationKey(db, args)
+	case "shareSecureAggregationKeys":
+		result, err = shareSecureAggregationKeys(db, args)
+	case "commitSecureAggregationMask":
+		result, err = commitSecureAggregationMask(db, args)
+	case "revealSecureAggregationShares":
+		result, err = revealSecureAggregationShares(db, args)
+	case "advanceSecureAggregation":
+		result, err = advanceSecureAggregation(db, args)
+	case "querySecureAggregation":
+		result, err = querySecureAggregation(db, args)
+	case "updateChannelConfig":
+		result, err = updateChannelConfig(db, args)
+	case "queryChannelConfig":
+		result, err = queryChannelConfig(db, args)
+	case "updateAccessControl":
+		result, err = updateAccessControl(db, args)
+	case "queryAccessControl":
+		result, err = queryAccessControl(db, args)
 	default:
 		err = errors.BadRequest("function \"%s\" not implemented", fn)
 	}
@@ -210,6 +294,11 @@ func (t *SubstraChaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Respons
 		return formatErrorResponse(err)
 	}
 
+	// Write the changes of the transaction, each key once
+	if err = db.Flush(); err != nil {
+		return formatErrorResponse(err)
+	}
+
 	// Add bookmark (if any) to response
 	if hasBookmark {
 		result = map[string]interface{}{
@@ -16,11 +16,18 @@ package main
 
 import (
 	"container/list"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
 	"strings"
 	"unicode/utf8"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/attrmgr"
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
 	"github.com/hyperledger/fabric-protos-go/msp"
@@ -44,6 +51,9 @@ type MockStub struct {
 	// The transaction creator
 	Creator string
 
+	// The attributes of the transaction creator certificate, if any
+	CreatorAttributes map[string]string
+
 	// arguments the stub was called with
 	args [][]byte
 
@@ -403,10 +413,38 @@ func (stub *MockStub) GetCreator() ([]byte, error) {
 		Mspid:   stub.Creator,
 		IdBytes: []byte(fakeCertificate),
 	}
+	if len(stub.CreatorAttributes) > 0 {
+		cert, err := certificateWithAttributes(stub.CreatorAttributes)
+		if err != nil {
+			return nil, err
+		}
+		sid.IdBytes = cert
+	}
 
 	return proto.Marshal(sid)
 }
 
+// certificateWithAttributes returns a self-signed PEM certificate holding the
+// given attributes the way the Fabric CA adds them.
+func certificateWithAttributes(attributes map[string]string) ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{SerialNumber: big.NewInt(1)}
+	err = attrmgr.New().AddAttributesToCert(&attrmgr.Attributes{Attrs: attributes}, template)
+	if err != nil {
+		return nil, err
+	}
+	// the attributes are added as parsed extensions, they must be marshalled as extra ones
+	template.ExtraExtensions = template.Extensions
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
 // Not implemented
 func (stub *MockStub) GetTransient() (map[string][]byte, error) {
 	return nil, nil
@@ -16,9 +16,56 @@ package main
 
 import (
 	"chaincode/errors"
+	"hash/fnv"
 )
 
+// List of the possible node's status
+const (
+	NodeStatusActive    = "active"
+	NodeStatusSuspended = "suspended"
+	NodeStatusRemoved   = "removed"
+)
+
+// GetStatus returns the status of the node. Nodes registered before the
+// introduction of the lifecycle are active.
+func (node *Node) GetStatus() string {
+	if node.Status == "" {
+		return NodeStatusActive
+	}
+	return node.Status
+}
+
+// setFromInput fills the description of the node from inputNode
+func (node *Node) setFromInput(db *LedgerDB, inp inputNode) error {
+	if err := checkMetadata(db, inp.Metadata); err != nil {
+		return err
+	}
+	node.Name = inp.Name
+	node.Contact = inp.Contact
+	node.Capabilities = NodeCapabilities(inp.Capabilities)
+	node.Metadata = inp.Metadata
+	return nil
+}
+
+// getNodeInput parses the optional inputNode argument of registerNode and updateNode
+func getNodeInput(args []string) (inp inputNode, found bool, err error) {
+	if len(args) > 1 {
+		err = errors.BadRequest("incorrect number of arguments, expecting at most one argument")
+		return
+	}
+	if len(args) == 0 || args[0] == "" {
+		return
+	}
+	err = AssetFromJSON(args, &inp)
+	return inp, err == nil, err
+}
+
 func registerNode(db *LedgerDB, args []string) (Node, error) {
+	inp, hasInput, err := getNodeInput(args)
+	if err != nil {
+		return Node{}, err
+	}
+
 	txCreator, err := GetTxCreator(db.cc)
 	if err != nil {
 		return Node{}, err
@@ -26,6 +73,12 @@ func registerNode(db *LedgerDB, args []string) (Node, error) {
 
 	node := Node{}
 	node.ID = txCreator
+	node.Status = NodeStatusActive
+	if hasInput {
+		if err = node.setFromInput(db, inp); err != nil {
+			return Node{}, err
+		}
+	}
 
 	// Not using db.Add because we need to handle conflict as silent event without errors
 	exists, err := db.KeyExists(node.ID)
@@ -34,7 +87,7 @@ func registerNode(db *LedgerDB, args []string) (Node, error) {
 	}
 
 	if exists {
-		return node, nil
+		return db.GetNode(node.ID)
 	}
 
 	err = db.Put(node.ID, node)
@@ -50,16 +103,83 @@ func registerNode(db *LedgerDB, args []string) (Node, error) {
 	return node, nil
 }
 
+// updateNode replaces the description of the transaction creator's node
+func updateNode(db *LedgerDB, args []string) (Node, error) {
+	inp, hasInput, err := getNodeInput(args)
+	if err != nil {
+		return Node{}, err
+	}
+	if !hasInput {
+		return Node{}, errors.BadRequest("incorrect number of arguments, expecting one argument")
+	}
+	txCreator, err := GetTxCreator(db.cc)
+	if err != nil {
+		return Node{}, err
+	}
+	node, err := db.GetNode(txCreator)
+	if err != nil {
+		return Node{}, err
+	}
+	if node.GetStatus() == NodeStatusRemoved {
+		return Node{}, errors.BadRequest("node %s has been removed", node.ID)
+	}
+	if err = node.setFromInput(db, inp); err != nil {
+		return Node{}, err
+	}
+	if err = db.Put(node.ID, node); err != nil {
+		return Node{}, err
+	}
+	return node, nil
+}
+
+// updateNodeStatus changes the status of a node. A node can suspend or remove
+// itself, but only an approved proposal can make it active again.
+// Removing a node is final.
+func updateNodeStatus(db *LedgerDB, args []string) (Node, error) {
+	inp := inputUpdateNodeStatus{}
+	if err := AssetFromJSON(args, &inp); err != nil {
+		return Node{}, err
+	}
+	node, err := db.GetNode(inp.NodeID)
+	if err != nil {
+		return Node{}, err
+	}
+	txCreator, err := GetTxCreator(db.cc)
+	if err != nil {
+		return Node{}, err
+	}
+	if txCreator != node.ID && !hasAdminRights(db) {
+		return Node{}, errors.Forbidden("only the node %s or an approved proposal can change its status", node.ID)
+	}
+	if inp.Status == NodeStatusActive && !hasAdminRights(db) {
+		return Node{}, errors.Forbidden("only an approved proposal can activate node %s", node.ID)
+	}
+	if node.GetStatus() == NodeStatusRemoved {
+		return Node{}, errors.BadRequest("node %s has been removed", node.ID)
+	}
+	node.Status = inp.Status
+	if err = db.Put(node.ID, node); err != nil {
+		return Node{}, err
+	}
+	return node, nil
+}
+
 func queryNodes(db *LedgerDB, args []string) (nodes []Node, err error) {
 	nodes = []Node{}
+	inp := inputQueryNodes{}
 
-	elementsKeys, err := db.GetIndexKeys("node~key", []string{"node"})
-
-	if len(args) != 0 {
+	if len(args) > 1 {
 		err = errors.BadRequest("incorrect number of arguments, expecting at most one argument")
 		return
 	}
+	if len(args) == 1 && args[0] != "" {
+		err = AssetFromJSON(args, &inp)
+		if err != nil {
+			return
+		}
+	}
 
+	elementsKeys, err := db.GetIndexKeys("node~key", []string{"node"})
 	if err != nil {
 		return
 	}
@@ -69,9 +189,168 @@ func queryNodes(db *LedgerDB, args []string) (nodes []Node, err error) {
 		if err != nil {
 			return nodes, err
 		}
+		if !inp.match(node) {
+			continue
+		}
 
 		nodes = append(nodes, node)
 	}
 
 	return
 }
+
+// match returns true if the node passes the queryNodes filters
+func (inp inputQueryNodes) match(node Node) bool {
+	if inp.Status != "" && node.GetStatus() != inp.Status {
+		return false
+	}
+	if inp.CPUOnly != nil && node.Capabilities.CPUOnly != *inp.CPUOnly {
+		return false
+	}
+	if inp.DataManagerType != "" && !stringInSlice(inp.DataManagerType, node.Capabilities.DataManagerTypes) {
+		return false
+	}
+	return true
+}
+
+// registerNodeGroup stores a named list of nodes owned by the transaction creator
+func registerNodeGroup(db *LedgerDB, args []string) (resp outputKey, err error) {
+	inp := inputNodeGroup{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	if err = validateAuthorizedIds(db, inp.NodeIDs); err != nil {
+		return
+	}
+	owner, err := GetTxCreator(db.cc)
+	if err != nil {
+		return
+	}
+	group := NodeGroup{
+		Key:       inp.Key,
+		AssetType: NodeGroupType,
+		Name:      inp.Name,
+		Owner:     owner,
+		NodeIDs:   inp.NodeIDs,
+	}
+	if err = db.Add(group.Key, group); err != nil {
+		return
+	}
+	if err = db.CreateIndex("nodeGroup~owner~key", []string{"nodeGroup", group.Owner, group.Key}); err != nil {
+		return
+	}
+	return outputKey{Key: group.Key}, nil
+}
+
+// updateNodeGroup replaces the members of a node group. Assets authorizing the
+// group are affected at once, tuples keep the nodes they were created with.
+func updateNodeGroup(db *LedgerDB, args []string) (resp outputKey, err error) {
+	inp := inputUpdateNodeGroup{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	group, err := db.GetNodeGroup(inp.Key)
+	if err != nil {
+		return
+	}
+	txCreator, err := GetTxCreator(db.cc)
+	if err != nil {
+		return
+	}
+	if txCreator != group.Owner {
+		err = errors.Forbidden("%s is not the owner of the node group %s", txCreator, group.Key)
+		return
+	}
+	if err = validateAuthorizedIds(db, inp.NodeIDs); err != nil {
+		return
+	}
+	group.NodeIDs = inp.NodeIDs
+	if err = db.Put(group.Key, group); err != nil {
+		return
+	}
+	return outputKey{Key: group.Key}, nil
+}
+
+func queryNodeGroup(db *LedgerDB, args []string) (out outputNodeGroup, err error) {
+	inp := inputKey{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	group, err := db.GetNodeGroup(inp.Key)
+	if err != nil {
+		return
+	}
+	out.Fill(group)
+	return
+}
+
+func queryNodeGroups(db *LedgerDB, args []string) (outGroups []outputNodeGroup, bookmark string, err error) {
+	inp := inputBookmark{}
+	outGroups = []outputNodeGroup{}
+
+	if len(args) > 1 {
+		err = errors.BadRequest("incorrect number of arguments, expecting at most one argument")
+		return
+	}
+
+	if len(args) == 1 && args[0] != "" {
+		err = AssetFromJSON(args, &inp)
+		if err != nil {
+			return
+		}
+	}
+
+	elementsKeys, bookmark, err := db.GetIndexKeysWithPagination("nodeGroup~owner~key", []string{"nodeGroup"}, db.config.PageSize, inp.Bookmark)
+	if err != nil {
+		return
+	}
+	for _, key := range elementsKeys {
+		group, err := db.GetNodeGroup(key)
+		if err != nil {
+			return outGroups, bookmark, err
+		}
+		var out outputNodeGroup
+		out.Fill(group)
+		outGroups = append(outGroups, out)
+	}
+	return
+}
+
+// selectWorker picks an active node offering the capabilities of the selector.
+// The choice among the candidates depends only on the tuple key, so that all
+// the endorsers resolve the same worker.
+func selectWorker(db *LedgerDB, selector inputWorkerSelector, tupleKey string) (string, error) {
+	nodes, err := queryNodes(db, []string{})
+	if err != nil {
+		return "", err
+	}
+	candidates := []string{}
+	for _, node := range nodes {
+		if node.GetStatus() == NodeStatusActive && selector.match(node) {
+			candidates = append(candidates, node.ID)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", errors.BadRequest("no active node matches the worker selector")
+	}
+	h := fnv.New32a()
+	h.Write([]byte(tupleKey))
+	return candidates[h.Sum32()%uint32(len(candidates))], nil
+}
+
+// match returns true if the node offers the capabilities of the selector
+func (selector inputWorkerSelector) match(node Node) bool {
+	if selector.CPUOnly != nil && node.Capabilities.CPUOnly != *selector.CPUOnly {
+		return false
+	}
+	if selector.MinMemory > node.Capabilities.MaxMemory {
+		return false
+	}
+	if selector.DataManagerType != "" && !stringInSlice(selector.DataManagerType, node.Capabilities.DataManagerTypes) {
+		return false
+	}
+	return true
+}
@@ -18,6 +18,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNode(t *testing.T) {
@@ -44,3 +45,183 @@ func TestQueryNodes(t *testing.T) {
 	assert.EqualValuesf(t, 200, response.Status, "Node Created")
 	assert.Contains(t, string(response.Payload), "\"id\":\"SampleOrg\"", "Query nodes")
 }
+
+func TestNodeGroupPermissions(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	registerWorker(mockStub, workerB)
+	registerWorker(mockStub, workerC)
+	mockStub.MockTransactionStart("42")
+	db := NewLedgerDB(mockStub)
+
+	inpGroup := inputNodeGroup{Key: RandomUUID(), Name: "partners", NodeIDs: []string{"unknown node"}}
+	_, err := registerNodeGroup(db, assetToArgs(inpGroup))
+	assert.Error(t, err, "a node group should only contain registered nodes")
+	inpGroup.NodeIDs = []string{workerB}
+	_, err = registerNodeGroup(db, assetToArgs(inpGroup))
+	require.NoError(t, err)
+
+	inpAlgo := inputAlgo{Key: RandomUUID()}
+	inpAlgo.fillDefaults()
+	inpAlgo.Permissions.Process = inputPermission{AuthorizedIDs: []string{}, AuthorizedGroups: []string{RandomUUID()}}
+	_, err = registerAlgo(db, assetToArgs(inpAlgo))
+	assert.Error(t, err, "permissions should only authorize existing node groups")
+	inpAlgo.Permissions.Process.AuthorizedGroups = []string{inpGroup.Key}
+	_, err = registerAlgo(db, assetToArgs(inpAlgo))
+	require.NoError(t, err)
+
+	algo, err := db.GetAlgo(inpAlgo.Key)
+	require.NoError(t, err)
+	canProcess, err := algo.Permissions.CanProcess(db, algo.Owner, workerB)
+	require.NoError(t, err)
+	assert.True(t, canProcess, "group members can process the algo")
+	canProcess, err = algo.Permissions.CanProcess(db, algo.Owner, workerC)
+	require.NoError(t, err)
+	assert.False(t, canProcess)
+
+	// adding a partner to the group grants it access to the algo
+	mockStub.Creator = workerB
+	_, err = updateNodeGroup(db, assetToArgs(inputUpdateNodeGroup{Key: inpGroup.Key, NodeIDs: []string{workerB, workerC}}))
+	assert.Error(t, err, "only the owner can update a node group")
+	mockStub.Creator = workerA
+	_, err = updateNodeGroup(db, assetToArgs(inputUpdateNodeGroup{Key: inpGroup.Key, NodeIDs: []string{workerB, workerC}}))
+	require.NoError(t, err)
+	canProcess, err = algo.Permissions.CanProcess(db, algo.Owner, workerC)
+	require.NoError(t, err)
+	assert.True(t, canProcess)
+
+	group, err := queryNodeGroup(db, keyToArgs(inpGroup.Key))
+	require.NoError(t, err)
+	assert.Equal(t, outputNodeGroup{Key: inpGroup.Key, Name: "partners", Owner: workerA, NodeIDs: []string{workerB, workerC}}, group)
+	groups, _, err := queryNodeGroups(db, []string{})
+	require.NoError(t, err)
+	assert.Len(t, groups, 1)
+}
+
+func TestNodeLifecycle(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	mockStub.MockTransactionStart("42")
+	db := NewLedgerDB(mockStub)
+
+	inp := inputNode{
+		Name:    "Hospital B",
+		Contact: "admin@hospital-b.org",
+		Capabilities: inputNodeCapabilities{
+			CPUOnly:          true,
+			MaxMemory:        16000,
+			DataManagerTypes: []string{"images"},
+		},
+	}
+	mockStub.Creator = workerB
+	node, err := registerNode(db, assetToArgs(inp))
+	require.NoError(t, err)
+	assert.Equal(t, "Hospital B", node.Name)
+	assert.Equal(t, NodeStatusActive, node.Status)
+	mockStub.Creator = workerC
+	_, err = registerNode(db, []string{})
+	require.NoError(t, err)
+
+	mockStub.Creator = workerB
+	inp.Contact = "it@hospital-b.org"
+	_, err = updateNode(db, assetToArgs(inp))
+	require.NoError(t, err)
+
+	filterTable := []struct {
+		filter   inputQueryNodes
+		expected int
+	}{
+		{inputQueryNodes{}, 3},
+		{inputQueryNodes{DataManagerType: "images"}, 1},
+		{inputQueryNodes{Status: NodeStatusActive}, 3},
+	}
+	for _, test := range filterTable {
+		nodes, err := queryNodes(db, assetToArgs(test.filter))
+		require.NoError(t, err)
+		assert.Len(t, nodes, test.expected)
+	}
+
+	// only the node itself or an approved proposal can change its status
+	mockStub.Creator = workerC
+	_, err = updateNodeStatus(db, assetToArgs(inputUpdateNodeStatus{NodeID: workerB, Status: NodeStatusSuspended}))
+	assert.Error(t, err)
+	mockStub.CreatorAttributes = map[string]string{RoleAttribute: "admin"}
+	_, err = updateNodeStatus(db, assetToArgs(inputUpdateNodeStatus{NodeID: workerB, Status: NodeStatusSuspended}))
+	assert.Error(t, err, "the certificate attributes do not grant admin rights")
+	mockStub.CreatorAttributes = nil
+	db.proposalKey = RandomUUID()
+	_, err = updateNodeStatus(db, assetToArgs(inputUpdateNodeStatus{NodeID: workerB, Status: NodeStatusSuspended}))
+	db.proposalKey = ""
+	require.NoError(t, err)
+	mockStub.Creator = workerB
+	_, err = updateNodeStatus(db, assetToArgs(inputUpdateNodeStatus{NodeID: workerB, Status: NodeStatusActive}))
+	assert.Error(t, err, "a node suspended by a proposal should not reactivate itself")
+	node, err = db.GetNode(workerB)
+	require.NoError(t, err)
+	assert.Equal(t, NodeStatusSuspended, node.GetStatus())
+	db.proposalKey = RandomUUID()
+	_, err = updateNodeStatus(db, assetToArgs(inputUpdateNodeStatus{NodeID: workerB, Status: NodeStatusActive}))
+	db.proposalKey = ""
+	require.NoError(t, err, "an approved proposal should reactivate the node")
+	_, err = updateNodeStatus(db, assetToArgs(inputUpdateNodeStatus{NodeID: workerB, Status: NodeStatusRemoved}))
+	require.NoError(t, err)
+	_, err = updateNodeStatus(db, assetToArgs(inputUpdateNodeStatus{NodeID: workerB, Status: NodeStatusActive}))
+	assert.Error(t, err, "removing a node is final")
+
+	nodes, err := queryNodes(db, assetToArgs(inputQueryNodes{Status: NodeStatusRemoved}))
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+	assert.Equal(t, "it@hospital-b.org", nodes[0].Contact)
+
+	// removed nodes cannot be authorized in permissions anymore
+	mockStub.Creator = workerA
+	inpAlgo := inputAlgo{Key: RandomUUID()}
+	inpAlgo.fillDefaults()
+	inpAlgo.Permissions.Process = inputPermission{AuthorizedIDs: []string{workerB}}
+	_, err = registerAlgo(db, assetToArgs(inpAlgo))
+	assert.Error(t, err)
+	inpAlgo.Permissions.Process = inputPermission{AuthorizedIDs: []string{workerC}}
+	_, err = registerAlgo(db, assetToArgs(inpAlgo))
+	assert.NoError(t, err)
+}
+
+func TestSuspendedNode(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	registerWorker(mockStub, workerB)
+	registerItem(t, *mockStub, "traintuple")
+	mockStub.MockTransactionStart("42")
+	db := NewLedgerDB(mockStub)
+
+	mockStub.Creator = workerB
+	_, err := updateNodeStatus(db, assetToArgs(inputUpdateNodeStatus{NodeID: workerB, Status: NodeStatusSuspended}))
+	require.NoError(t, err)
+	mockStub.Creator = workerA
+
+	// suspended nodes cannot be authorized
+	inpAlgo := inputAlgo{Key: RandomUUID()}
+	inpAlgo.fillDefaults()
+	inpAlgo.Permissions.Process = inputPermission{AuthorizedIDs: []string{workerB}}
+	_, err = registerAlgo(db, assetToArgs(inpAlgo))
+	assert.Error(t, err)
+
+	// nor start new tuples
+	_, err = updateNodeStatus(db, assetToArgs(inputUpdateNodeStatus{NodeID: workerA, Status: NodeStatusSuspended}))
+	require.NoError(t, err)
+	_, err = logStartTrain(db, assetToArgs(inputKey{Key: traintupleKey}))
+	assert.Error(t, err)
+	db.proposalKey = RandomUUID()
+	_, err = updateNodeStatus(db, assetToArgs(inputUpdateNodeStatus{NodeID: workerA, Status: NodeStatusActive}))
+	db.proposalKey = ""
+	require.NoError(t, err)
+	_, err = logStartTrain(db, assetToArgs(inputKey{Key: traintupleKey}))
+	require.NoError(t, err)
+
+	// but can finish the running ones
+	_, err = updateNodeStatus(db, assetToArgs(inputUpdateNodeStatus{NodeID: workerA, Status: NodeStatusSuspended}))
+	require.NoError(t, err)
+	success := inputLogSuccessTrain{}
+	success.fillDefaults()
+	_, err = logSuccessTrain(db, assetToArgs(success))
+	assert.NoError(t, err)
+}
@@ -16,7 +16,17 @@ package main
 
 import (
 	"chaincode/errors"
-	"sort"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Ranking modes of the leaderboard
+const (
+	RankingAll                = "all"
+	RankingBestPerAlgo        = "best_per_algo"
+	RankingBestPerAlgoFamily  = "best_per_algo_family"
+	RankingBestPerComputePlan = "best_per_compute_plan"
 )
 
 // Set is a method of the receiver Objective. It checks the validity of inputObjective and uses its fields to set the Objective.
@@ -24,20 +34,11 @@ import (
 func (objective *Objective) Set(db *LedgerDB, inp inputObjective) (dataManagerKey string, err error) {
 	dataManagerKey = inp.TestDataset.DataManagerKey
 	if dataManagerKey != "" {
-		var testOnly bool
-		dataSampleKeys := inp.TestDataset.DataSampleKeys
-		testOnly, _, err = checkSameDataManager(db, dataManagerKey, dataSampleKeys)
+		objective.TestDataset, err = newObjectiveTestDataset(db, dataManagerKey, inp.TestDataset.DataSampleKeys)
 		if err != nil {
-			err = errors.BadRequest(err, "invalid test dataSample")
-			return
-		} else if !testOnly {
-			err = errors.BadRequest("test dataSample are not tagged as testOnly dataSample")
 			return
 		}
-		objective.TestDataset = &Dataset{
-			DataManagerKey: dataManagerKey,
-			DataSampleKeys: dataSampleKeys,
-		}
+		objective.TestDatasetVersion = 1
 	} else {
 		objective.TestDataset = nil
 	}
@@ -53,7 +54,24 @@ func (objective *Objective) Set(db *LedgerDB, inp inputObjective) (dataManagerKe
 		Checksum:       inp.MetricsChecksum,
 		StorageAddress: inp.MetricsStorageAddress,
 	}
+	objective.MetricNames = inp.MetricNames
+	err = checkMetadata(db, inp.Metadata)
+	if err != nil {
+		return
+	}
 	objective.Metadata = inp.Metadata
+	objective.Blind = inp.Blind
+	if inp.Challenge != (inputChallenge{}) {
+		if inp.Challenge.ClosingTimestamp != 0 && inp.Challenge.ClosingTimestamp <= inp.Challenge.OpeningTimestamp {
+			err = errors.BadRequest("challenge closing timestamp should be after its opening timestamp")
+			return
+		}
+		objective.Challenge = &Challenge{
+			OpeningTimestamp:              inp.Challenge.OpeningTimestamp,
+			ClosingTimestamp:              inp.Challenge.ClosingTimestamp,
+			MaxCertifiedTesttuplesPerNode: inp.Challenge.MaxCertifiedTesttuplesPerNode,
+		}
+	}
 	owner, err := GetTxCreator(db.cc)
 	if err != nil {
 		return
@@ -67,6 +85,82 @@ func (objective *Objective) Set(db *LedgerDB, inp inputObjective) (dataManagerKe
 	return
 }
 
+// GetMetricNames returns the names of the metrics computed by the objective.
+// The first one is the main metric. Objectives registered without metric names
+// only compute the main metric, named after the metrics.
+func (objective *Objective) GetMetricNames() []string {
+	if len(objective.MetricNames) > 0 {
+		return objective.MetricNames
+	}
+	if objective.Metrics == nil {
+		return []string{}
+	}
+	return []string{objective.Metrics.Name}
+}
+
+// GetTestDatasetVersion returns the version of the current test dataset of the objective.
+// Objectives registered before test datasets were versioned are at their first version.
+func (objective *Objective) GetTestDatasetVersion() int {
+	if objective.TestDatasetVersion == 0 && objective.TestDataset != nil {
+		return 1
+	}
+	return objective.TestDatasetVersion
+}
+
+// isChallengeClosed returns true if the objective challenge is closed at the given timestamp
+func (objective *Objective) isChallengeClosed(timestamp int64) bool {
+	return objective.Challenge != nil && objective.Challenge.ClosingTimestamp != 0 && timestamp >= objective.Challenge.ClosingTimestamp
+}
+
+// checkChallengeSubmission checks that a node can submit a new certified testtuple
+// to the objective challenge: the challenge is open and the node has not used
+// all its submissions yet.
+func (objective *Objective) checkChallengeSubmission(db *LedgerDB, node string) error {
+	if objective.Challenge == nil {
+		return nil
+	}
+	timestamp, err := db.GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+	if timestamp < objective.Challenge.OpeningTimestamp {
+		return errors.BadRequest("challenge of objective %s is not open yet", objective.Key)
+	}
+	if objective.isChallengeClosed(timestamp) {
+		return errors.BadRequest("challenge of objective %s is closed", objective.Key)
+	}
+	if objective.Challenge.MaxCertifiedTesttuplesPerNode == 0 {
+		return nil
+	}
+	keys, err := db.GetIndexKeys("testtuple~objective~certified~creator~key", []string{"testtuple", objective.Key, "true", node})
+	if err != nil {
+		return err
+	}
+	if len(keys) >= objective.Challenge.MaxCertifiedTesttuplesPerNode {
+		return errors.BadRequest("node %s already submitted %d certified testtuples to the challenge of objective %s", node, len(keys), objective.Key)
+	}
+	return nil
+}
+
+// sealsScores returns true if the scores of the objective certified testtuples
+// are only visible to the objective owner
+func (objective *Objective) sealsScores() bool {
+	return objective.Blind && !objective.ScoresRevealed
+}
+
+// hidesScores returns true if the scores of the objective certified testtuples
+// must be hidden to the transaction creator
+func (objective *Objective) hidesScores(db *LedgerDB) (bool, error) {
+	if !objective.sealsScores() {
+		return false, nil
+	}
+	txCreator, err := GetTxCreator(db.cc)
+	if err != nil {
+		return false, err
+	}
+	return txCreator != objective.Owner, nil
+}
+
 // -------------------------------------------------------------------------------------------
 // Smart contract related to objectivess
 // -------------------------------------------------------------------------------------------
@@ -100,6 +194,92 @@ func registerObjective(db *LedgerDB, args []string) (resp outputKey, err error)
 	return outputKey{Key: objective.Key}, err
 }
 
+// updateObjectiveTestDataset replaces the test dataset of an objective by a new version.
+// Previous versions are kept so testtuples certified against them stay comparable.
+// Once the objective is certified, only an approved proposal can replace it.
+func updateObjectiveTestDataset(db *LedgerDB, args []string) (resp outputKey, err error) {
+	inp := inputUpdateObjectiveTestDataset{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	objective, err := db.GetObjective(inp.ObjectiveKey)
+	if err != nil {
+		return
+	}
+	txCreator, err := GetTxCreator(db.cc)
+	if err != nil {
+		return
+	}
+	if txCreator != objective.Owner && !hasAdminRights(db) {
+		err = errors.Forbidden("%s is not the owner of the objective %s", txCreator, inp.ObjectiveKey)
+		return
+	}
+	// replacing the test dataset of a certified objective changes its leaderboard
+	if objective.TestDataset != nil && !hasAdminRights(db) {
+		err = errors.Forbidden("the test dataset of the certified objective %s can only be changed by an approved proposal", inp.ObjectiveKey)
+		return
+	}
+	if objective.TestDataset != nil && objective.TestDataset.DataManagerKey == inp.DataManagerKey &&
+		isEqual(objective.TestDataset.DataSampleKeys, inp.DataSampleKeys) {
+		err = errors.BadRequest("objective %s already uses this test dataset", inp.ObjectiveKey)
+		return
+	}
+	testDataset, err := newObjectiveTestDataset(db, inp.DataManagerKey, inp.DataSampleKeys)
+	if err != nil {
+		return
+	}
+	dataManager, err := db.GetDataManager(inp.DataManagerKey)
+	if err != nil {
+		return
+	}
+	if dataManager.ObjectiveKey != objective.Key {
+		if err = addObjectiveDataManager(db, inp.DataManagerKey, objective.Key); err != nil {
+			return
+		}
+	}
+
+	if objective.TestDataset != nil {
+		objective.PreviousTestDatasets = append(objective.PreviousTestDatasets, objective.TestDataset)
+	}
+	objective.TestDatasetVersion = objective.GetTestDatasetVersion() + 1
+	objective.TestDataset = testDataset
+	if err = db.Put(objective.Key, objective); err != nil {
+		return
+	}
+	return outputKey{Key: objective.Key}, nil
+}
+
+// revealObjectiveScores makes the scores of a blind objective public
+func revealObjectiveScores(db *LedgerDB, args []string) (resp outputKey, err error) {
+	inp := inputKey{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	objective, err := db.GetObjective(inp.Key)
+	if err != nil {
+		return
+	}
+	txCreator, err := GetTxCreator(db.cc)
+	if err != nil {
+		return
+	}
+	if txCreator != objective.Owner {
+		err = errors.Forbidden("%s is not the owner of the objective %s", txCreator, inp.Key)
+		return
+	}
+	if !objective.Blind {
+		err = errors.BadRequest("objective %s is not blind", inp.Key)
+		return
+	}
+	objective.ScoresRevealed = true
+	if err = db.Put(objective.Key, objective); err != nil {
+		return
+	}
+	return outputKey{Key: objective.Key}, nil
+}
+
 // queryObjective returns a objective of the ledger given its key
 func queryObjective(db *LedgerDB, args []string) (out outputObjective, err error) {
 	inp := inputKey{}
@@ -132,7 +312,7 @@ func queryObjectives(db *LedgerDB, args []string) (outObjectives []outputObjecti
 		}
 	}
 
-	elementsKeys, bookmark, err := db.GetIndexKeysWithPagination("objective~owner~key", []string{"objective"}, OutputPageSize, inp.Bookmark)
+	elementsKeys, bookmark, err := db.GetIndexKeysWithPagination("objective~owner~key", []string{"objective"}, db.config.PageSize, inp.Bookmark)
 
 	if err != nil {
 		return
@@ -149,56 +329,155 @@ func queryObjectives(db *LedgerDB, args []string) (outObjectives []outputObjecti
 	return
 }
 
-// getObjectiveLeaderboard returns for an objective, all its certified testtuples with a done status, ordered by their perf
-// It can be an ascending sort or not depending on the ascendingOrder value.
+// queryObjectiveLeaderboard returns for an objective, a page of its certified testtuples with a done status,
+// ordered by their perf. It can be an ascending sort or not depending on the ascendingOrder value.
+// Only testtuples certified against the requested test dataset version, by default the current one, are ranked.
+// Testtuples are read from a sorted index so filters are applied page by page: a page can hold less
+// testtuples than the page size while the bookmark still points to the following ones.
+// Testtuples done before the introduction of this index are ranked once indexObjectiveLeaderboard is called.
 func queryObjectiveLeaderboard(db *LedgerDB, args []string) (outputLeaderboard, error) {
 	inp := inputLeaderboard{}
 	err := AssetFromJSON(args, &inp)
 	if err != nil {
 		return outputLeaderboard{}, err
 	}
+	if inp.RankingMode == "" && inp.BestPerAlgoFamily {
+		inp.RankingMode = RankingBestPerAlgoFamily
+	}
 
 	objective, err := db.GetObjective(inp.ObjectiveKey)
 	if err != nil {
 		return outputLeaderboard{}, err
 	}
+	// the ranking itself would disclose the scores
+	hidden, err := objective.hidesScores(db)
+	if err != nil {
+		return outputLeaderboard{}, err
+	}
+	if hidden {
+		return outputLeaderboard{}, errors.Forbidden("scores of objective %s are not revealed yet", inp.ObjectiveKey)
+	}
+	if inp.Metric != "" && !stringInSlice(inp.Metric, objective.GetMetricNames()) {
+		return outputLeaderboard{}, errors.BadRequest("metric %s is not declared by objective %s", inp.Metric, inp.ObjectiveKey)
+	}
+	if inp.Metric != "" && inp.RankingMode != "" && inp.RankingMode != RankingAll {
+		return outputLeaderboard{}, errors.BadRequest("ranking mode %s is only available for the main metric", inp.RankingMode)
+	}
 	outObjective := outputObjective{}
 	outObjective.Fill(objective)
 	out := outputLeaderboard{Objective: outObjective, Testtuples: []outputBoardTuple{}}
 
-	testtupleKeys, err := db.GetIndexKeys("testtuple~objective~certified~key", []string{"testtuple", inp.ObjectiveKey, "true"})
+	version := inp.TestDatasetVersion
+	if version == 0 {
+		version = objective.GetTestDatasetVersion()
+	}
+	if version > objective.GetTestDatasetVersion() {
+		return outputLeaderboard{}, errors.BadRequest("objective %s has no test dataset version %d", inp.ObjectiveKey, version)
+	}
+	order := getLeaderboardOrder(inp.AscendingOrder)
+	testtupleKeys, bookmark, err := db.GetIndexKeysWithPagination(
+		"testtuple~objective~version~metric~order~perf~key",
+		[]string{"testtuple", inp.ObjectiveKey, strconv.Itoa(version), inp.Metric, order},
+		db.config.PageSize,
+		inp.Bookmark,
+	)
 	if err != nil {
 		return outputLeaderboard{}, err
 	}
 
+	// the page is sorted, once a group is ranked its following testtuples are not the best ones
+	rankedGroups := map[string]bool{}
 	for _, testtupleKey := range testtupleKeys {
-		var boardTuple outputBoardTuple
 		testtuple, err := db.GetTesttuple(testtupleKey)
 		if err != nil {
 			return outputLeaderboard{}, err
 		}
-		if testtuple.Status != StatusDone {
-			continue
-		}
+		var boardTuple outputBoardTuple
 		err = boardTuple.Fill(db, testtuple, testtupleKey)
 		if err != nil {
 			return outputLeaderboard{}, err
 		}
+		if !inp.match(testtuple, boardTuple) {
+			continue
+		}
+		groupKey := inp.getRankingGroupKey(testtuple, boardTuple)
+		if groupKey != "" {
+			if rankedGroups[groupKey] {
+				continue
+			}
+			isBest, err := isBestInRankingGroup(db, inp, order, groupKey, testtuple, boardTuple)
+			if err != nil {
+				return outputLeaderboard{}, err
+			}
+			if !isBest {
+				continue
+			}
+			rankedGroups[groupKey] = true
+		}
 		out.Testtuples = append(out.Testtuples, boardTuple)
 	}
+	out.Bookmark = bookmark
+	return out, nil
+}
 
-	if inp.AscendingOrder {
-		sort.Sort(out.Testtuples)
-	} else {
-		sort.Sort(sort.Reverse(out.Testtuples))
+// indexObjectiveLeaderboard adds to the leaderboard of an objective its done certified testtuples
+// which are not ranked yet, namely the ones done before the introduction of the leaderboard index.
+func indexObjectiveLeaderboard(db *LedgerDB, args []string) (resp outputKey, err error) {
+	inp := inputKey{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
 	}
-	return out, nil
+	if _, err = db.GetObjective(inp.Key); err != nil {
+		return
+	}
+	testtupleKeys, err := db.GetIndexKeys("testtuple~objective~certified~key", []string{"testtuple", inp.Key, "true"})
+	if err != nil {
+		return
+	}
+	for _, testtupleKey := range testtupleKeys {
+		var testtuple Testtuple
+		testtuple, err = db.GetTesttuple(testtupleKey)
+		if err != nil {
+			return
+		}
+		if testtuple.Status != StatusDone {
+			continue
+		}
+		var indexed bool
+		indexed, err = isInLeaderboard(db, testtuple, testtupleKey)
+		if err != nil {
+			return
+		}
+		if indexed {
+			continue
+		}
+		err = createLeaderboardIndexes(db, testtuple, testtupleKey)
+		if err != nil {
+			return
+		}
+	}
+	return outputKey{Key: inp.Key}, nil
 }
 
 // -------------------------------------------------------------------------------------------
 // Utils for objectivess
 // -------------------------------------------------------------------------------------------
 
+// newObjectiveTestDataset checks that the dataSamples can be used as the test dataset of an objective
+func newObjectiveTestDataset(db *LedgerDB, dataManagerKey string, dataSampleKeys []string) (*Dataset, error) {
+	testOnly, _, err := checkSameDataManager(db, dataManagerKey, dataSampleKeys)
+	if err != nil {
+		return nil, errors.BadRequest(err, "invalid test dataSample")
+	} else if !testOnly {
+		return nil, errors.BadRequest("test dataSample are not tagged as testOnly dataSample")
+	}
+	return &Dataset{
+		DataManagerKey: dataManagerKey,
+		DataSampleKeys: dataSampleKeys,
+	}, nil
+}
+
 // addObjectiveDataManager associates a objective to a dataManager, more precisely, it adds the objective key to the dataManager
 func addObjectiveDataManager(db *LedgerDB, dataManagerKey string, objectiveKey string) error {
 	dataManager, err := db.GetDataManager(dataManagerKey)
@@ -211,3 +490,171 @@ func addObjectiveDataManager(db *LedgerDB, dataManagerKey string, objectiveKey s
 	dataManager.ObjectiveKey = objectiveKey
 	return db.Put(dataManagerKey, dataManager)
 }
+
+// createLeaderboardIndexes adds a done certified testtuple to the sorted indexes read by the leaderboard.
+// The testtuple is indexed for its main perf and for each metric it reported, in both orders. Only the
+// main perf is indexed within each ranking group. Testtuples outside of a compute plan are not grouped
+// by compute plan.
+// The leaderboard of a challenge is frozen once it is closed.
+func createLeaderboardIndexes(db *LedgerDB, testtuple Testtuple, testtupleKey string) error {
+	objective, err := db.GetObjective(testtuple.ObjectiveKey)
+	if err != nil {
+		return err
+	}
+	if objective.isChallengeClosed(testtuple.DoneTimestamp) {
+		return nil
+	}
+	algo, err := db.GetAlgoOfAnyType(testtuple.AlgoKey)
+	if err != nil {
+		return err
+	}
+	groups := map[string]string{
+		RankingBestPerAlgo:        testtuple.AlgoKey,
+		RankingBestPerAlgoFamily:  algo.GetFamilyKey(),
+		RankingBestPerComputePlan: testtuple.ComputePlanKey,
+	}
+	version := strconv.Itoa(testtuple.GetTestDatasetVersion())
+	perfs := map[string]float32{"": testtuple.Dataset.Perf}
+	for metric, perf := range testtuple.Dataset.Perfs {
+		perfs[metric] = perf
+	}
+
+	for metric, perf := range perfs {
+		for _, ascending := range []bool{true, false} {
+			order := getLeaderboardOrder(ascending)
+			sortKey := getLeaderboardSortKey(perf, ascending)
+			if err := db.CreateIndex(
+				"testtuple~objective~version~metric~order~perf~key",
+				[]string{"testtuple", testtuple.ObjectiveKey, version, metric, order, sortKey, testtupleKey},
+			); err != nil {
+				return err
+			}
+			if metric != "" {
+				continue
+			}
+			for rankingMode, groupKey := range groups {
+				if groupKey == "" {
+					continue
+				}
+				if err := db.CreateIndex(
+					"testtuple~objective~version~metric~order~group~perf~key",
+					[]string{"testtuple", testtuple.ObjectiveKey, version, metric, order, rankingMode, groupKey, sortKey, testtupleKey},
+				); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// isInLeaderboard returns true if a testtuple is already in the sorted indexes read by the leaderboard
+func isInLeaderboard(db *LedgerDB, testtuple Testtuple, testtupleKey string) (bool, error) {
+	order := getLeaderboardOrder(true)
+	compositeKey, err := db.cc.CreateCompositeKey(
+		"testtuple~objective~version~metric~order~perf~key",
+		[]string{"testtuple", testtuple.ObjectiveKey, strconv.Itoa(testtuple.GetTestDatasetVersion()), "", order, getLeaderboardSortKey(testtuple.Dataset.Perf, true), testtupleKey},
+	)
+	if err != nil {
+		return false, errors.Internal(err)
+	}
+	return db.KeyExists(compositeKey)
+}
+
+// getRankingGroupKey returns the group of the testtuple for the requested ranking mode,
+// or an empty string if the testtuple is not ranked within a group
+func (inp inputLeaderboard) getRankingGroupKey(testtuple Testtuple, boardTuple outputBoardTuple) string {
+	switch inp.RankingMode {
+	case RankingBestPerAlgo:
+		return testtuple.AlgoKey
+	case RankingBestPerAlgoFamily:
+		return boardTuple.AlgoFamilyKey
+	case RankingBestPerComputePlan:
+		return testtuple.ComputePlanKey
+	}
+	return ""
+}
+
+// isBestInRankingGroup returns true if the testtuple comes first in its group, among the testtuples
+// of the group which pass the leaderboard filters. Without filters, only the top key of the group
+// index is read. With filters, the group is read up to one page: a testtuple ranked below a full
+// page of filtered out testtuples of its group is left out.
+func isBestInRankingGroup(db *LedgerDB, inp inputLeaderboard, order string, groupKey string, testtuple Testtuple, boardTuple outputBoardTuple) (bool, error) {
+	pageSize := db.config.PageSize
+	if !inp.hasFilters() {
+		pageSize = 1
+	}
+	groupKeys, _, err := db.GetIndexKeysWithPagination(
+		"testtuple~objective~version~metric~order~group~perf~key",
+		[]string{"testtuple", testtuple.ObjectiveKey, strconv.Itoa(testtuple.GetTestDatasetVersion()), inp.Metric, order, inp.RankingMode, groupKey},
+		pageSize,
+		"",
+	)
+	if err != nil {
+		return false, err
+	}
+	for _, key := range groupKeys {
+		if key == boardTuple.Key {
+			return true, nil
+		}
+		other, err := db.GetTesttuple(key)
+		if err != nil {
+			return false, err
+		}
+		var otherBoardTuple outputBoardTuple
+		if err = otherBoardTuple.Fill(db, other, key); err != nil {
+			return false, err
+		}
+		if inp.match(other, otherBoardTuple) {
+			return false, nil
+		}
+	}
+	return false, nil
+}
+
+// hasFilters returns true if some testtuples can be filtered out of the leaderboard
+func (inp inputLeaderboard) hasFilters() bool {
+	return inp.Owner != "" || inp.ComputePlanKey != "" || inp.Tag != "" || inp.DoneAfter != 0 || inp.DoneBefore != 0
+}
+
+// match returns true if the testtuple passes the leaderboard filters
+func (inp inputLeaderboard) match(testtuple Testtuple, boardTuple outputBoardTuple) bool {
+	if inp.Owner != "" && boardTuple.AlgoOwner != inp.Owner {
+		return false
+	}
+	if inp.ComputePlanKey != "" && testtuple.ComputePlanKey != inp.ComputePlanKey {
+		return false
+	}
+	if inp.Tag != "" && testtuple.Tag != inp.Tag {
+		return false
+	}
+	if inp.DoneAfter != 0 && testtuple.DoneTimestamp < inp.DoneAfter {
+		return false
+	}
+	if inp.DoneBefore != 0 && testtuple.DoneTimestamp > inp.DoneBefore {
+		return false
+	}
+	return true
+}
+
+func getLeaderboardOrder(ascending bool) string {
+	if ascending {
+		return "asc"
+	}
+	return "desc"
+}
+
+// getLeaderboardSortKey encodes a perf in a fixed length string whose lexicographic
+// order is the numeric order of the perf, or the reverse one if ascending is false.
+func getLeaderboardSortKey(perf float32, ascending bool) string {
+	bits := math.Float32bits(perf)
+	if bits&(1<<31) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 31
+	}
+	if !ascending {
+		bits = ^bits
+	}
+	return fmt.Sprintf("%08x", bits)
+}
@@ -33,6 +33,7 @@ func TestLeaderBoard(t *testing.T) {
 	db := NewLedgerDB(mockStub)
 	registerItem(t, *mockStub, "")
 	mockStub.MockTransactionStart("42")
+	traintupleToDone(t, db, traintupleKey)
 
 	// Add a certified testtuple
 	inputTest := inputTesttuple{
@@ -52,13 +53,7 @@ func TestLeaderBoard(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Len(t, leaderboard.Testtuples, 0)
 
-	// Update testtuple status directly
-	testtuple, err := db.GetTesttuple(keyMap.Key)
-	assert.NoError(t, err)
-	testtuple.Status = StatusDone
-	testtuple.Dataset.Perf = 0.9
-	err = db.Put(keyMap.Key, testtuple)
-	assert.NoError(t, err)
+	testtupleToDoneWithPerf(t, db, keyMap.Key, 0.9)
 
 	leaderboard, err = queryObjectiveLeaderboard(db, assetToArgs(inpLeaderboard))
 	assert.NoError(t, err)
@@ -71,6 +66,401 @@ func TestLeaderBoard(t *testing.T) {
 	assert.Equal(t, algoName, leaderboard.Testtuples[0].Algo.Name)
 	assert.Equal(t, algoStorageAddress, leaderboard.Testtuples[0].Algo.StorageAddress)
 }
+func TestLeaderBoardBestPerAlgoFamily(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	db := NewLedgerDB(mockStub)
+	registerItem(t, *mockStub, "compositeTraintuple")
+	mockStub.MockTransactionStart("42")
+
+	// register a second version of the algo and train it
+	newVersion := inputAlgo{Key: RandomUUID(), ParentKey: algoKey}
+	newVersion.fillDefaults()
+	_, err := registerAlgo(db, assetToArgs(newVersion))
+	require.NoError(t, err)
+	newTraintuple := inputTraintuple{Key: RandomUUID(), AlgoKey: newVersion.Key}
+	newTraintuple.createDefault()
+	_, err = createTraintuple(db, assetToArgs(newTraintuple))
+	require.NoError(t, err)
+	trainToDone(t, mockStub, db, traintupleKey, newTraintuple.Key, compositeTraintupleKey)
+
+	perfs := map[string]float32{
+		traintupleKey:          0.5,
+		newTraintuple.Key:      0.9,
+		compositeTraintupleKey: 0.7,
+	}
+	testtupleKeys := map[string]string{}
+	for traintupleKey, perf := range perfs {
+		inputTest := inputTesttuple{
+			Key:           RandomUUID(),
+			TraintupleKey: traintupleKey,
+			ObjectiveKey:  objectiveKey,
+		}
+		_, err = createTesttuple(db, assetToArgs(inputTest))
+		require.NoError(t, err)
+		testtupleToDoneWithPerf(t, db, inputTest.Key, perf)
+		testtupleKeys[traintupleKey] = inputTest.Key
+	}
+
+	inpLeaderboard := inputLeaderboard{
+		ObjectiveKey:      objectiveKey,
+		AscendingOrder:    false,
+		BestPerAlgoFamily: true,
+	}
+	leaderboard, err := queryObjectiveLeaderboard(db, assetToArgs(inpLeaderboard))
+	require.NoError(t, err)
+	require.Len(t, leaderboard.Testtuples, 2)
+	assert.Equal(t, testtupleKeys[newTraintuple.Key], leaderboard.Testtuples[0].Key)
+	assert.Equal(t, algoKey, leaderboard.Testtuples[0].AlgoFamilyKey)
+	assert.Equal(t, testtupleKeys[compositeTraintupleKey], leaderboard.Testtuples[1].Key)
+	assert.Equal(t, compositeAlgoKey, leaderboard.Testtuples[1].AlgoFamilyKey)
+
+	inpLeaderboard.BestPerAlgoFamily = false
+	leaderboard, err = queryObjectiveLeaderboard(db, assetToArgs(inpLeaderboard))
+	require.NoError(t, err)
+	assert.Len(t, leaderboard.Testtuples, 3)
+}
+
+func TestLeaderBoardPagination(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	db := NewLedgerDB(mockStub)
+	registerItem(t, *mockStub, "compositeTraintuple")
+	mockStub.MockTransactionStart("42")
+
+	// train a second traintuple with the same algo, tagged and in a compute plan
+	inpCP := inputNewComputePlan{}
+	inpCP.Key = RandomUUID()
+	inpCP.Tag = "cp"
+	_, err := createComputePlan(db, assetToArgs(inpCP))
+	require.NoError(t, err)
+	newTraintuple := inputTraintuple{Key: RandomUUID(), ComputePlanKey: inpCP.Key, Rank: "0", Tag: "cp"}
+	newTraintuple.createDefault()
+	_, err = createTraintuple(db, assetToArgs(newTraintuple))
+	require.NoError(t, err)
+	trainToDone(t, mockStub, db, traintupleKey, newTraintuple.Key, compositeTraintupleKey)
+
+	// register more done testtuples than a single page can hold
+	nbTesttuples := OutputPageSize + 10
+	trainKeys := []string{traintupleKey, newTraintuple.Key, compositeTraintupleKey}
+	for i := 0; i < nbTesttuples; i++ {
+		inputTest := inputTesttuple{
+			Key:           RandomUUID(),
+			TraintupleKey: trainKeys[i%len(trainKeys)],
+			ObjectiveKey:  objectiveKey,
+		}
+		if inputTest.TraintupleKey == newTraintuple.Key {
+			inputTest.Tag = "cp"
+		}
+		_, err = createTesttuple(db, assetToArgs(inputTest))
+		require.NoError(t, err)
+		testtupleToDoneWithPerf(t, db, inputTest.Key, float32(i)/float32(nbTesttuples))
+	}
+
+	inpLeaderboard := inputLeaderboard{ObjectiveKey: objectiveKey}
+	leaderboard, err := queryObjectiveLeaderboard(db, assetToArgs(inpLeaderboard))
+	require.NoError(t, err)
+	require.Len(t, leaderboard.Testtuples, OutputPageSize)
+	for i := 1; i < len(leaderboard.Testtuples); i++ {
+		assert.True(t, leaderboard.Testtuples[i-1].Perf >= leaderboard.Testtuples[i].Perf, "testtuples should be sorted by descending perf")
+	}
+	inpLeaderboard.Bookmark = leaderboard.Bookmark
+	nextPage, err := queryObjectiveLeaderboard(db, assetToArgs(inpLeaderboard))
+	require.NoError(t, err)
+	require.Len(t, nextPage.Testtuples, 10)
+	assert.True(t, leaderboard.Testtuples[OutputPageSize-1].Perf >= nextPage.Testtuples[0].Perf)
+
+	inpLeaderboard = inputLeaderboard{ObjectiveKey: objectiveKey, AscendingOrder: true, RankingMode: RankingBestPerAlgo}
+	leaderboard, err = queryObjectiveLeaderboard(db, assetToArgs(inpLeaderboard))
+	require.NoError(t, err)
+	require.Len(t, leaderboard.Testtuples, 2)
+	assert.EqualValues(t, 0, leaderboard.Testtuples[0].Perf)
+	assert.Equal(t, algoKey, leaderboard.Testtuples[0].Algo.Key)
+	assert.Equal(t, compositeAlgoKey, leaderboard.Testtuples[1].Algo.Key)
+
+	// filters are applied before picking the best testtuple of each group
+	inpLeaderboard = inputLeaderboard{ObjectiveKey: objectiveKey, AscendingOrder: true, RankingMode: RankingBestPerAlgo, Tag: "cp"}
+	leaderboard, err = queryObjectiveLeaderboard(db, assetToArgs(inpLeaderboard))
+	require.NoError(t, err)
+	require.Len(t, leaderboard.Testtuples, 1)
+	assert.Equal(t, algoKey, leaderboard.Testtuples[0].Algo.Key)
+	assert.Equal(t, "cp", leaderboard.Testtuples[0].Tag)
+
+	inpLeaderboard = inputLeaderboard{ObjectiveKey: objectiveKey, RankingMode: RankingBestPerComputePlan, ComputePlanKey: inpCP.Key}
+	leaderboard, err = queryObjectiveLeaderboard(db, assetToArgs(inpLeaderboard))
+	require.NoError(t, err)
+	require.Len(t, leaderboard.Testtuples, 1)
+	assert.Equal(t, inpCP.Key, leaderboard.Testtuples[0].ComputePlanKey)
+
+	// filters are applied page by page
+	inpLeaderboard = inputLeaderboard{ObjectiveKey: objectiveKey, Tag: "cp", Owner: workerA}
+	nbFiltered := 0
+	for {
+		leaderboard, err = queryObjectiveLeaderboard(db, assetToArgs(inpLeaderboard))
+		require.NoError(t, err)
+		for _, boardTuple := range leaderboard.Testtuples {
+			assert.Equal(t, "cp", boardTuple.Tag)
+		}
+		nbFiltered += len(leaderboard.Testtuples)
+		if leaderboard.Bookmark == "" {
+			break
+		}
+		inpLeaderboard.Bookmark = leaderboard.Bookmark
+	}
+	assert.Equal(t, nbTesttuples/len(trainKeys), nbFiltered)
+
+	inpLeaderboard = inputLeaderboard{ObjectiveKey: objectiveKey, Owner: workerB}
+	leaderboard, err = queryObjectiveLeaderboard(db, assetToArgs(inpLeaderboard))
+	require.NoError(t, err)
+	assert.Len(t, leaderboard.Testtuples, 0)
+
+	inpLeaderboard = inputLeaderboard{ObjectiveKey: objectiveKey, DoneBefore: 1}
+	leaderboard, err = queryObjectiveLeaderboard(db, assetToArgs(inpLeaderboard))
+	require.NoError(t, err)
+	assert.Len(t, leaderboard.Testtuples, 0)
+}
+
+func TestUpdateObjectiveTestDataset(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	db := NewLedgerDB(mockStub)
+	registerItem(t, *mockStub, "traintuple")
+	mockStub.MockTransactionStart("42")
+	traintupleToDone(t, db, traintupleKey)
+
+	firstTesttuple := inputTesttuple{Key: RandomUUID(), TraintupleKey: traintupleKey, ObjectiveKey: objectiveKey}
+	_, err := createTesttuple(db, assetToArgs(firstTesttuple))
+	require.NoError(t, err)
+	testtupleToDoneWithPerf(t, db, firstTesttuple.Key, 0.5)
+
+	// register new test dataSamples
+	newSampleKeys := []string{RandomUUID(), RandomUUID()}
+	inpDataSample := inputDataSample{Keys: newSampleKeys, TestOnly: "true"}
+	inpDataSample.createDefault()
+	_, err = registerDataSample(db, assetToArgs(inpDataSample))
+	require.NoError(t, err)
+
+	inp := inputUpdateObjectiveTestDataset{
+		ObjectiveKey:   objectiveKey,
+		DataManagerKey: dataManagerKey,
+		DataSampleKeys: newSampleKeys,
+	}
+	mockStub.Creator = workerB
+	_, err = updateObjectiveTestDataset(db, assetToArgs(inp))
+	assert.Error(t, err, "only the objective owner can update its test dataset")
+	mockStub.Creator = workerA
+
+	_, err = updateObjectiveTestDataset(db, assetToArgs(inputUpdateObjectiveTestDataset{
+		ObjectiveKey:   objectiveKey,
+		DataManagerKey: dataManagerKey,
+		DataSampleKeys: []string{trainDataSampleKey1},
+	}))
+	assert.Error(t, err, "train dataSamples cannot be used as test dataset")
+
+	_, err = updateObjectiveTestDataset(db, assetToArgs(inp))
+	assert.Error(t, err, "the owner cannot change the test dataset of a certified objective")
+
+	db.proposalKey = RandomUUID()
+	_, err = updateObjectiveTestDataset(db, assetToArgs(inp))
+	require.NoError(t, err)
+	_, err = updateObjectiveTestDataset(db, assetToArgs(inp))
+	assert.Error(t, err, "the test dataset is already the current one")
+	db.proposalKey = ""
+
+	objective, err := queryObjective(db, keyToArgs(objectiveKey))
+	require.NoError(t, err)
+	assert.Equal(t, 2, objective.TestDatasetVersion)
+	assert.Equal(t, newSampleKeys, objective.TestDataset.DataSampleKeys)
+	require.Len(t, objective.PreviousTestDatasets, 1)
+	assert.Equal(t, []string{testDataSampleKey1, testDataSampleKey2}, objective.PreviousTestDatasets[0].DataSampleKeys)
+
+	secondTesttuple := inputTesttuple{Key: RandomUUID(), TraintupleKey: traintupleKey, ObjectiveKey: objectiveKey}
+	_, err = createTesttuple(db, assetToArgs(secondTesttuple))
+	require.NoError(t, err)
+	testtupleToDoneWithPerf(t, db, secondTesttuple.Key, 0.7)
+	testtuple, err := queryTesttuple(db, keyToArgs(secondTesttuple.Key))
+	require.NoError(t, err)
+	assert.True(t, testtuple.Certified)
+	assert.Equal(t, 2, testtuple.TestDatasetVersion)
+	assert.Equal(t, newSampleKeys, testtuple.Dataset.DataSampleKeys)
+
+	// the leaderboard only compares testtuples certified on the same test dataset
+	inpLeaderboard := inputLeaderboard{ObjectiveKey: objectiveKey}
+	leaderboard, err := queryObjectiveLeaderboard(db, assetToArgs(inpLeaderboard))
+	require.NoError(t, err)
+	require.Len(t, leaderboard.Testtuples, 1)
+	assert.Equal(t, secondTesttuple.Key, leaderboard.Testtuples[0].Key)
+
+	inpLeaderboard.TestDatasetVersion = 1
+	leaderboard, err = queryObjectiveLeaderboard(db, assetToArgs(inpLeaderboard))
+	require.NoError(t, err)
+	require.Len(t, leaderboard.Testtuples, 1)
+	assert.Equal(t, firstTesttuple.Key, leaderboard.Testtuples[0].Key)
+
+	inpLeaderboard.TestDatasetVersion = 3
+	_, err = queryObjectiveLeaderboard(db, assetToArgs(inpLeaderboard))
+	assert.Error(t, err)
+}
+
+func TestObjectiveChallenge(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	db := NewLedgerDB(mockStub)
+	registerItem(t, *mockStub, "traintuple")
+	mockStub.MockTransactionStart("42")
+	traintupleToDone(t, db, traintupleKey)
+
+	inpObjective := inputObjective{Key: RandomUUID()}
+	inpObjective.createDefault()
+	inpObjective.TestDataset = inputDataset{}
+	inpObjective.Challenge = inputChallenge{OpeningTimestamp: 10, ClosingTimestamp: 10}
+	_, err := registerObjective(db, assetToArgs(inpObjective))
+	assert.Error(t, err, "a challenge should close after it opens")
+
+	now, err := db.GetTxTimestamp()
+	require.NoError(t, err)
+	objective, err := db.GetObjective(objectiveKey)
+	require.NoError(t, err)
+	objective.Challenge = &Challenge{
+		OpeningTimestamp:              now + 100,
+		ClosingTimestamp:              now + 200,
+		MaxCertifiedTesttuplesPerNode: 2,
+	}
+	require.NoError(t, db.Put(objectiveKey, objective))
+
+	newTesttuple := func() inputTesttuple {
+		return inputTesttuple{Key: RandomUUID(), TraintupleKey: traintupleKey, ObjectiveKey: objectiveKey}
+	}
+	_, err = createTesttuple(db, assetToArgs(newTesttuple()))
+	assert.Error(t, err, "the challenge is not open yet")
+
+	mockStub.TxTimestamp.Seconds = now + 100
+	testtupleKeys := []string{}
+	for i := 0; i < 2; i++ {
+		inp := newTesttuple()
+		_, err = createTesttuple(db, assetToArgs(inp))
+		require.NoError(t, err)
+		testtupleKeys = append(testtupleKeys, inp.Key)
+	}
+	_, err = createTesttuple(db, assetToArgs(newTesttuple()))
+	assert.Error(t, err, "the node used all its submissions")
+
+	mockStub.Creator = workerB
+	_, err = createTesttuple(db, assetToArgs(newTesttuple()))
+	assert.NoError(t, err, "other nodes have their own submissions")
+	mockStub.Creator = workerA
+
+	testtupleToDoneWithPerf(t, db, testtupleKeys[0], 0.5)
+	mockStub.TxTimestamp.Seconds = now + 200
+	_, err = createTesttuple(db, assetToArgs(newTesttuple()))
+	assert.Error(t, err, "the challenge is closed")
+
+	// the leaderboard is frozen at close
+	testtupleToDoneWithPerf(t, db, testtupleKeys[1], 0.9)
+	leaderboard, err := queryObjectiveLeaderboard(db, assetToArgs(inputLeaderboard{ObjectiveKey: objectiveKey}))
+	require.NoError(t, err)
+	require.Len(t, leaderboard.Testtuples, 1)
+	assert.Equal(t, testtupleKeys[0], leaderboard.Testtuples[0].Key)
+}
+
+func TestBlindObjective(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	db := NewLedgerDB(mockStub)
+	registerItem(t, *mockStub, "traintuple")
+	mockStub.MockTransactionStart("42")
+	traintupleToDone(t, db, traintupleKey)
+
+	objective, err := db.GetObjective(objectiveKey)
+	require.NoError(t, err)
+	objective.Blind = true
+	require.NoError(t, db.Put(objectiveKey, objective))
+
+	inpTesttuple := inputTesttuple{Key: RandomUUID(), TraintupleKey: traintupleKey, ObjectiveKey: objectiveKey}
+	_, err = createTesttuple(db, assetToArgs(inpTesttuple))
+	require.NoError(t, err)
+	testtupleToDoneWithPerf(t, db, inpTesttuple.Key, 0.8)
+	inpLeaderboard := inputLeaderboard{ObjectiveKey: objectiveKey}
+
+	// the objective owner sees the scores
+	testtuple, err := queryTesttuple(db, keyToArgs(inpTesttuple.Key))
+	require.NoError(t, err)
+	assert.False(t, testtuple.ScoresHidden)
+	assert.EqualValues(t, 0.8, testtuple.Dataset.Perf)
+	leaderboard, err := queryObjectiveLeaderboard(db, assetToArgs(inpLeaderboard))
+	require.NoError(t, err)
+	assert.Len(t, leaderboard.Testtuples, 1)
+
+	// other nodes do not
+	mockStub.Creator = workerB
+	testtuple, err = queryTesttuple(db, keyToArgs(inpTesttuple.Key))
+	require.NoError(t, err)
+	assert.True(t, testtuple.ScoresHidden)
+	assert.EqualValues(t, 0, testtuple.Dataset.Perf)
+	_, err = queryObjectiveLeaderboard(db, assetToArgs(inpLeaderboard))
+	assert.Error(t, err)
+	_, err = revealObjectiveScores(db, keyToArgs(objectiveKey))
+	assert.Error(t, err, "only the objective owner can reveal its scores")
+
+	mockStub.Creator = workerA
+	_, err = revealObjectiveScores(db, keyToArgs(objectiveKey))
+	require.NoError(t, err)
+
+	mockStub.Creator = workerB
+	testtuple, err = queryTesttuple(db, keyToArgs(inpTesttuple.Key))
+	require.NoError(t, err)
+	assert.False(t, testtuple.ScoresHidden)
+	assert.EqualValues(t, 0.8, testtuple.Dataset.Perf)
+	leaderboard, err = queryObjectiveLeaderboard(db, assetToArgs(inpLeaderboard))
+	require.NoError(t, err)
+	assert.Len(t, leaderboard.Testtuples, 1)
+	mockStub.Creator = workerA
+}
+
+func TestBlindObjectiveTransactionPayloads(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	db := NewLedgerDB(mockStub)
+	registerItem(t, *mockStub, "traintuple")
+	mockStub.MockTransactionStart("42")
+	traintupleToDone(t, db, traintupleKey)
+
+	objective, err := db.GetObjective(objectiveKey)
+	require.NoError(t, err)
+	objective.Blind = true
+	require.NoError(t, db.Put(objectiveKey, objective))
+	inpTesttuple := inputTesttuple{Key: RandomUUID(), TraintupleKey: traintupleKey, ObjectiveKey: objectiveKey}
+	_, err = createTesttuple(db, assetToArgs(inpTesttuple))
+	require.NoError(t, err)
+	_, err = logStartTest(db, assetToArgs(inputKey{Key: inpTesttuple.Key}))
+	require.NoError(t, err)
+	require.NoError(t, db.Flush())
+	for len(mockStub.ChaincodeEventsChannel) > 0 {
+		<-mockStub.ChaincodeEventsChannel
+	}
+
+	// the objective owner logs the result, but the response and the events
+	// are readable by all the nodes of the channel
+	perf := float32(0.8)
+	success := inputLogSuccessTest{Perf: &perf}
+	success.Key = inpTesttuple.Key
+	resp := mockStub.MockInvoke(methodAndAssetToByte("logSuccessTest", success))
+	require.EqualValues(t, 200, resp.Status, resp.Message)
+	out := outputTesttuple{}
+	require.NoError(t, json.Unmarshal(resp.Payload, &out))
+	assert.True(t, out.ScoresHidden)
+	assert.EqualValues(t, 0, out.Dataset.Perf)
+	for len(mockStub.ChaincodeEventsChannel) > 0 {
+		event := <-mockStub.ChaincodeEventsChannel
+		assert.NotContains(t, string(event.Payload), "0.8")
+	}
+
+	// the score is stored and visible to the objective owner
+	testtuple, err := queryTesttuple(NewLedgerDB(mockStub), keyToArgs(inpTesttuple.Key))
+	require.NoError(t, err)
+	assert.EqualValues(t, 0.8, testtuple.Dataset.Perf)
+}
+
 func TestRegisterObjectiveWhitoutDataset(t *testing.T) {
 	scc := new(SubstraChaincode)
 	mockStub := NewMockStubWithRegisterNode("substra", scc)
@@ -169,15 +559,19 @@ func TestObjective(t *testing.T) {
 			StorageAddress: inpObjective.DescriptionStorageAddress,
 			Checksum:       objectiveDescriptionChecksum,
 		},
-		Permissions: outputPermissions{
-			Process: Permission{Public: true, AuthorizedIDs: []string{}},
+		Permissions: outputPermissionsFull{
+			outputPermissions: outputPermissions{Process: Permission{Public: true, AuthorizedIDs: []string{}, AuthorizedGroups: []string{}}},
+			Download:          Permission{Public: true, AuthorizedIDs: []string{}, AuthorizedGroups: []string{}},
 		},
 		Metrics: &ChecksumAddressName{
 			Checksum:       inpObjective.MetricsChecksum,
 			Name:           inpObjective.MetricsName,
 			StorageAddress: inpObjective.MetricsStorageAddress,
 		},
-		Metadata: map[string]string{},
+		MetricNames:          []string{inpObjective.MetricsName},
+		Metadata:             map[string]string{},
+		TestDatasetVersion:   1,
+		PreviousTestDatasets: []*Dataset{},
 	}
 	assert.Exactly(t, expectedObjective, objective)
 
@@ -191,3 +585,63 @@ func TestObjective(t *testing.T) {
 	assert.Len(t, objectives.Results, 1)
 	assert.Exactly(t, expectedObjective, objectives.Results[0], "return objective different from registered one")
 }
+
+func trainToDone(t *testing.T, mockStub *MockStub, db *LedgerDB, traintupleKey, newTraintupleKey, compositeTraintupleKey string) {
+	for _, key := range []string{traintupleKey, newTraintupleKey} {
+		_, err := logStartTrain(db, assetToArgs(inputKey{Key: key}))
+		require.NoError(t, err)
+		success := inputLogSuccessTrain{}
+		success.Key = key
+		success.fillDefaults()
+		success.OutModel.Key = RandomUUID()
+		_, err = logSuccessTrain(db, assetToArgs(success))
+		require.NoError(t, err)
+	}
+	compositeToDone(t, mockStub, workerA, db, compositeTraintupleKey, RandomUUID(), RandomUUID())
+}
+
+func testtupleToDoneWithPerf(t *testing.T, db *LedgerDB, key string, perf float32) {
+	_, err := logStartTest(db, assetToArgs(inputKey{Key: key}))
+	require.NoError(t, err)
+	success := inputLogSuccessTest{}
+	success.Key = key
+	success.createDefault()
+	success.Perf = &perf
+	_, err = logSuccessTest(db, assetToArgs(success))
+	require.NoError(t, err)
+}
+
+func TestIndexObjectiveLeaderboard(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	db := NewLedgerDB(mockStub)
+	registerItem(t, *mockStub, "traintuple")
+	mockStub.MockTransactionStart("42")
+	traintupleToDone(t, db, traintupleKey)
+
+	inpTesttuple := inputTesttuple{Key: RandomUUID(), TraintupleKey: traintupleKey, ObjectiveKey: objectiveKey}
+	_, err := createTesttuple(db, assetToArgs(inpTesttuple))
+	require.NoError(t, err)
+	testtupleToDoneWithPerf(t, db, inpTesttuple.Key, 0.5)
+
+	// a testtuple done before the introduction of the leaderboard index
+	legacy, err := db.GetTesttuple(inpTesttuple.Key)
+	require.NoError(t, err)
+	legacy.DoneTimestamp = 0
+	legacyKey := RandomUUID()
+	require.NoError(t, db.Add(legacyKey, legacy))
+	require.NoError(t, db.CreateIndex("testtuple~objective~certified~key", []string{"testtuple", objectiveKey, "true", legacyKey}))
+
+	leaderboard, err := queryObjectiveLeaderboard(db, assetToArgs(inputLeaderboard{ObjectiveKey: objectiveKey}))
+	require.NoError(t, err)
+	assert.Len(t, leaderboard.Testtuples, 1)
+
+	_, err = indexObjectiveLeaderboard(db, keyToArgs(objectiveKey))
+	require.NoError(t, err)
+	_, err = indexObjectiveLeaderboard(db, keyToArgs(objectiveKey))
+	require.NoError(t, err)
+	leaderboard, err = queryObjectiveLeaderboard(db, assetToArgs(inputLeaderboard{ObjectiveKey: objectiveKey}))
+	require.NoError(t, err)
+	require.Len(t, leaderboard.Testtuples, 2)
+	assert.ElementsMatch(t, []string{inpTesttuple.Key, legacyKey}, []string{leaderboard.Testtuples[0].Key, leaderboard.Testtuples[1].Key})
+}
@@ -25,14 +25,21 @@ const OutputPageSize = 500
 // Struct use as output representation of ledger data
 
 type outputObjective struct {
-	Key         string               `json:"key"`
-	Name        string               `json:"name"`
-	Description *ChecksumAddress     `json:"description"`
-	Metrics     *ChecksumAddressName `json:"metrics"`
-	Owner       string               `json:"owner"`
-	TestDataset *Dataset             `json:"test_dataset"`
-	Permissions outputPermissions    `json:"permissions"`
-	Metadata    map[string]string    `json:"metadata"`
+	Key         string                `json:"key"`
+	Name        string                `json:"name"`
+	Description *ChecksumAddress      `json:"description"`
+	Metrics     *ChecksumAddressName  `json:"metrics"`
+	MetricNames []string              `json:"metric_names"`
+	Owner       string                `json:"owner"`
+	TestDataset *Dataset              `json:"test_dataset"`
+	Permissions outputPermissionsFull `json:"permissions"`
+	Metadata    map[string]string     `json:"metadata"`
+
+	TestDatasetVersion   int        `json:"test_dataset_version"`
+	PreviousTestDatasets []*Dataset `json:"previous_test_datasets"`
+	Challenge            *Challenge `json:"challenge"`
+	Blind                bool       `json:"blind"`
+	ScoresRevealed       bool       `json:"scores_revealed"`
 }
 
 func (out *outputObjective) Fill(in Objective) {
@@ -40,6 +47,7 @@ func (out *outputObjective) Fill(in Objective) {
 	out.Name = in.Name
 	out.Description = in.Description
 	out.Metrics = in.Metrics
+	out.MetricNames = in.GetMetricNames()
 	out.Owner = in.Owner
 	out.TestDataset = in.TestDataset
 	if out.TestDataset != nil {
@@ -47,19 +55,39 @@ func (out *outputObjective) Fill(in Objective) {
 	}
 	out.Permissions.Fill(in.Permissions)
 	out.Metadata = initMapOutput(in.Metadata)
+	out.Challenge = in.Challenge
+	out.Blind = in.Blind
+	out.ScoresRevealed = in.ScoresRevealed
+	out.TestDatasetVersion = in.GetTestDatasetVersion()
+	out.PreviousTestDatasets = []*Dataset{}
+	for _, dataset := range in.PreviousTestDatasets {
+		// copy the dataset so that the output does not share it with the objective
+		previous := &Dataset{
+			DataManagerKey: dataset.DataManagerKey,
+			DataSampleKeys: append([]string{}, dataset.DataSampleKeys...),
+			Metadata:       map[string]string{},
+			Worker:         dataset.Worker,
+		}
+		for key, value := range dataset.Metadata {
+			previous.Metadata[key] = value
+		}
+		out.PreviousTestDatasets = append(out.PreviousTestDatasets, previous)
+	}
 }
 
 // outputDataManager is the return representation of the DataManager type stored in the ledger
 type outputDataManager struct {
-	ObjectiveKey string            `json:"objective_key"`
-	Description  *ChecksumAddress  `json:"description"`
-	Key          string            `json:"key"`
-	Metadata     map[string]string `json:"metadata"`
-	Name         string            `json:"name"`
-	Opener       *ChecksumAddress  `json:"opener"`
-	Owner        string            `json:"owner"`
-	Permissions  outputPermissions `json:"permissions"`
-	Type         string            `json:"type"`
+	ObjectiveKey string                `json:"objective_key"`
+	Description  *ChecksumAddress      `json:"description"`
+	Key          string                `json:"key"`
+	Metadata     map[string]string     `json:"metadata"`
+	Name         string                `json:"name"`
+	Opener       *ChecksumAddress      `json:"opener"`
+	Owner        string                `json:"owner"`
+	Permissions  outputPermissionsFull `json:"permissions"`
+	Type         string                `json:"type"`
+
+	UsagePolicy *UsagePolicy `json:"usage_policy"`
 }
 
 func (out *outputDataManager) Fill(in DataManager) {
@@ -72,18 +100,48 @@ func (out *outputDataManager) Fill(in DataManager) {
 	out.Owner = in.Owner
 	out.Permissions.Fill(in.Permissions)
 	out.Type = in.Type
+	out.UsagePolicy = in.UsagePolicy
 }
 
 type outputDataSample struct {
 	DataManagerKeys []string `json:"data_manager_keys"`
 	Owner           string   `json:"owner"`
 	Key             string   `json:"key"`
+	Revoked         bool     `json:"revoked"`
 }
 
 func (out *outputDataSample) Fill(key string, in DataSample) {
 	out.Key = key
 	out.DataManagerKeys = in.DataManagerKeys
 	out.Owner = in.Owner
+	out.Revoked = in.Revoked
+}
+
+type outputRevokeDataSample struct {
+	Keys              []string `json:"keys"`
+	CanceledTupleKeys []string `json:"canceled_tuple_keys"`
+}
+
+// outputDataSampleUsage is the summary of a tuple which used a dataSample
+type outputDataSampleUsage struct {
+	Key            string         `json:"key"`
+	Type           string         `json:"type"`
+	AlgoKey        string         `json:"algo_key"`
+	ComputePlanKey string         `json:"compute_plan_key"`
+	Creator        string         `json:"creator"`
+	OutModels      []*KeyChecksum `json:"out_models"`
+	Status         string         `json:"status"`
+	Tag            string         `json:"tag"`
+	Worker         string         `json:"worker"`
+}
+
+func (out *outputDataSampleUsage) fillTuple(algoKey, computePlanKey, creator, status, tag, worker string) {
+	out.AlgoKey = algoKey
+	out.ComputePlanKey = computePlanKey
+	out.Creator = creator
+	out.Status = status
+	out.Tag = tag
+	out.Worker = worker
 }
 
 type outputDataset struct {
@@ -101,13 +159,19 @@ func (out *outputDataset) Fill(in DataManager, trainKeys []string, testKeys []st
 }
 
 type outputAlgo struct {
-	Key         string            `json:"key"`
-	Name        string            `json:"name"`
-	Content     *ChecksumAddress  `json:"content"`
-	Description *ChecksumAddress  `json:"description"`
-	Owner       string            `json:"owner"`
-	Permissions outputPermissions `json:"permissions"`
-	Metadata    map[string]string `json:"metadata"`
+	Key            string                `json:"key"`
+	Name           string                `json:"name"`
+	Content        *ChecksumAddress      `json:"content"`
+	Description    *ChecksumAddress      `json:"description"`
+	Owner          string                `json:"owner"`
+	Permissions    outputPermissionsFull `json:"permissions"`
+	Metadata       map[string]string     `json:"metadata"`
+	Status         string                `json:"status"`
+	StatusReason   string                `json:"status_reason"`
+	ReplacementKey string                `json:"replacement_key"`
+	ParentKey      string                `json:"parent_key"`
+	FamilyKey      string                `json:"family_key"`
+	Version        int                   `json:"version"`
 }
 
 func (out *outputAlgo) Fill(in Algo) {
@@ -121,6 +185,12 @@ func (out *outputAlgo) Fill(in Algo) {
 	out.Owner = in.Owner
 	out.Permissions.Fill(in.Permissions)
 	out.Metadata = initMapOutput(in.Metadata)
+	out.Status = in.GetStatus()
+	out.StatusReason = in.StatusReason
+	out.ReplacementKey = in.ReplacementKey
+	out.ParentKey = in.ParentKey
+	out.FamilyKey = in.GetFamilyKey()
+	out.Version = in.GetVersion()
 }
 
 // outputTtDataset is the representation of a Traintuple Dataset
@@ -144,7 +214,7 @@ type outputTraintuple struct {
 	Log            string                  `json:"log"`
 	Metadata       map[string]string       `json:"metadata"`
 	OutModel       *KeyChecksumAddress     `json:"out_model"`
-	Permissions    outputPermissions       `json:"permissions"`
+	Permissions    outputPermissionsFull   `json:"permissions"`
 	Rank           int                     `json:"rank"`
 	Status         string                  `json:"status"`
 	Tag            string                  `json:"tag"`
@@ -219,6 +289,7 @@ type outputTesttuple struct {
 	ComputePlanKey string                  `json:"compute_plan_key"`
 	Creator        string                  `json:"creator"`
 	Dataset        *TtDataset              `json:"dataset"`
+	DoneTimestamp  int64                   `json:"done_timestamp"`
 	Key            string                  `json:"key"`
 	Log            string                  `json:"log"`
 	Metadata       map[string]string       `json:"metadata"`
@@ -228,6 +299,9 @@ type outputTesttuple struct {
 	Tag            string                  `json:"tag"`
 	TraintupleKey  string                  `json:"traintuple_key"`
 	TraintupleType string                  `json:"traintuple_type"`
+
+	TestDatasetVersion int  `json:"test_dataset_version"`
+	ScoresHidden       bool `json:"scores_hidden"`
 }
 
 func (out *outputTesttuple) Fill(db *LedgerDB, in Testtuple) error {
@@ -236,6 +310,7 @@ func (out *outputTesttuple) Fill(db *LedgerDB, in Testtuple) error {
 	out.ComputePlanKey = in.ComputePlanKey
 	out.Creator = in.Creator
 	out.Dataset = in.Dataset
+	out.DoneTimestamp = in.DoneTimestamp
 	out.Log = in.Log
 	out.Metadata = initMapOutput(in.Metadata)
 	out.Rank = in.Rank
@@ -249,6 +324,22 @@ func (out *outputTesttuple) Fill(db *LedgerDB, in Testtuple) error {
 		return errors.Internal("could not retrieve traintuple type with key %s - %s", in.TraintupleKey, err.Error())
 	}
 	out.TraintupleType = traintupleType.String()
+	out.TestDatasetVersion = in.GetTestDatasetVersion()
+
+	// hide the scores of blind objectives
+	if in.Certified {
+		objective, err := db.GetObjective(in.ObjectiveKey)
+		if err != nil {
+			return errors.Internal("could not retrieve objective with key %s - %s", in.ObjectiveKey, err.Error())
+		}
+		hidden, err := objective.hidesScores(db)
+		if err != nil {
+			return err
+		}
+		if hidden {
+			out.hideScores()
+		}
+	}
 
 	// fill algo
 	var algo Algo
@@ -296,6 +387,37 @@ func (out *outputTesttuple) Fill(db *LedgerDB, in Testtuple) error {
 	return nil
 }
 
+// FillForChannel fills the output of a testtuple sent to all the nodes of the
+// channel, in events and transaction responses: the scores of a blind objective
+// are hidden whoever the transaction creator is.
+func (out *outputTesttuple) FillForChannel(db *LedgerDB, in Testtuple) error {
+	if err := out.Fill(db, in); err != nil {
+		return err
+	}
+	if !in.Certified {
+		return nil
+	}
+	objective, err := db.GetObjective(in.ObjectiveKey)
+	if err != nil {
+		return errors.Internal("could not retrieve objective with key %s - %s", in.ObjectiveKey, err.Error())
+	}
+	if objective.sealsScores() {
+		out.hideScores()
+	}
+	return nil
+}
+
+// hideScores removes the perfs from the output, without altering the testtuple dataset
+func (out *outputTesttuple) hideScores() {
+	out.ScoresHidden = true
+	if out.Dataset != nil {
+		dataset := *out.Dataset
+		dataset.Perf = 0
+		dataset.Perfs = nil
+		out.Dataset = &dataset
+	}
+}
+
 type outputModelDetails struct {
 	Aggregatetuple         *outputAggregatetuple      `json:"aggregatetuple,omitempty"`
 	CompositeTraintuple    *outputCompositeTraintuple `json:"composite_traintuple,omitempty"`
@@ -311,10 +433,43 @@ type outputModelListItem struct {
 }
 
 type outputModel struct {
-	Key            string                `json:"key"`
-	StorageAddress string                `json:"storage_address"`
-	Permissions    outputPermissionsFull `json:"permissions"`
-	Owner          string                `json:"owner"`
+	Key                   string                `json:"key"`
+	StorageAddress        string                `json:"storage_address"`
+	Permissions           outputPermissionsFull `json:"permissions"`
+	Owner                 string                `json:"owner"`
+	RevokedDataSampleKeys []string              `json:"revoked_data_sample_keys"`
+
+	PermissionsRule string `json:"permissions_rule,omitempty"` // only set for aggregatetuple out-models
+}
+
+// outputProvenanceTuple is one of the ancestor tuples returned by queryModelProvenance
+type outputProvenanceTuple struct {
+	Key          string                   `json:"key"`
+	Type         string                   `json:"type"`
+	Algo         *KeyChecksum             `json:"algo"`
+	Dataset      *outputProvenanceDataset `json:"dataset"`
+	Depth        int                      `json:"depth"`
+	OutHeadModel *KeyChecksum             `json:"out_head_model,omitempty"`
+	OutModel     *KeyChecksum             `json:"out_model"`
+	Parents      []string                 `json:"parents"`
+	Status       string                   `json:"status"`
+	Worker       string                   `json:"worker"`
+}
+
+// outputProvenanceDataset is the dataset used to train an ancestor tuple
+type outputProvenanceDataset struct {
+	DataManagerKey string   `json:"data_manager_key"`
+	DataSampleKeys []string `json:"data_sample_keys"`
+}
+
+func newOutputProvenanceDataset(in *Dataset) *outputProvenanceDataset {
+	if in == nil {
+		return nil
+	}
+	return &outputProvenanceDataset{
+		DataManagerKey: in.DataManagerKey,
+		DataSampleKeys: in.DataSampleKeys,
+	}
 }
 
 // Event is the collection of tuples sent in an event
@@ -324,6 +479,20 @@ type Event struct {
 	CompositeTraintuples []outputCompositeTraintuple `json:"composite_traintuple"`
 	Aggregatetuples      []outputAggregatetuple      `json:"aggregatetuple"`
 	ComputePlans         []eventComputePlan          `json:"compute_plan"`
+	PermissionsChanges   []eventPermissionsChange    `json:"permissions_change"`
+}
+
+// eventPermissionsChange lists the nodes affected by an update of the permissions of an asset
+type eventPermissionsChange struct {
+	Key       string                `json:"key"`
+	AssetType string                `json:"asset_type"`
+	Process   eventPermissionChange `json:"process"`
+	Download  eventPermissionChange `json:"download"`
+}
+
+type eventPermissionChange struct {
+	Granted []string `json:"granted"`
+	Revoked []string `json:"revoked"`
 }
 
 type eventComputePlan struct {
@@ -346,6 +515,8 @@ type outputComputePlan struct {
 	TupleCount              int               `json:"tuple_count"`
 	DoneCount               int               `json:"done_count"`
 	IDToKey                 map[string]string `json:"id_to_key"`
+
+	MinAggregationWorkers int `json:"min_aggregation_workers"`
 }
 
 func (out *outputComputePlan) Fill(key string, in ComputePlan, newIDs []string, doneCount int, tupleCount int) {
@@ -368,6 +539,7 @@ func (out *outputComputePlan) Fill(key string, in ComputePlan, newIDs []string,
 	}
 	out.IDToKey = IDToKey
 	out.CleanModels = in.CleanModels
+	out.MinAggregationWorkers = in.MinAggregationWorkers
 }
 
 // This is the "historical" output permissions, not
@@ -379,8 +551,12 @@ type outputPermissions struct {
 func (out *outputPermissions) Fill(in Permissions) {
 	out.Process.Public = in.Process.Public
 	out.Process.AuthorizedIDs = []string{}
+	out.Process.AuthorizedGroups = []string{}
 	if !in.Process.Public {
 		out.Process.AuthorizedIDs = in.Process.AuthorizedIDs
+		if in.Process.AuthorizedGroups != nil {
+			out.Process.AuthorizedGroups = in.Process.AuthorizedGroups
+		}
 	}
 }
 
@@ -393,43 +569,152 @@ func (out *outputPermissionsFull) Fill(in Permissions) {
 	out.outputPermissions.Fill(in)
 	out.Download.Public = in.Download.Public
 	out.Download.AuthorizedIDs = []string{}
+	out.Download.AuthorizedGroups = []string{}
 	if !in.Download.Public {
 		out.Download.AuthorizedIDs = in.Download.AuthorizedIDs
+		if in.Download.AuthorizedGroups != nil {
+			out.Download.AuthorizedGroups = in.Download.AuthorizedGroups
+		}
 	}
 }
 
-type outputLeaderboard struct {
-	Objective  outputObjective   `json:"objective"`
-	Testtuples outputBoardTuples `json:"testtuples"`
+type outputAccessControl struct {
+	Roles map[string][]string `json:"roles"`
 }
 
-type outputBoardTuples []outputBoardTuple
+func (out *outputAccessControl) Fill(in AccessControl) {
+	out.Roles = in.Roles
+}
+
+type outputProposal struct {
+	Key                string         `json:"key"`
+	Proposer           string         `json:"proposer"`
+	Description        string         `json:"description"`
+	Action             string         `json:"action"`
+	Args               string         `json:"args"`
+	Quorum             int            `json:"quorum"`
+	Votes              []ProposalVote `json:"votes"`
+	Status             string         `json:"status"`
+	CreationTimestamp  int64          `json:"creation_timestamp"`
+	ExecutionTimestamp int64          `json:"execution_timestamp"`
+	ExecutedBy         string         `json:"executed_by"`
+}
+
+func (out *outputProposal) Fill(in Proposal) {
+	out.Key = in.Key
+	out.Proposer = in.Proposer
+	out.Description = in.Description
+	out.Action = in.Action
+	out.Args = in.Args
+	out.Quorum = in.Quorum
+	out.Votes = in.Votes
+	out.Status = in.Status
+	out.CreationTimestamp = in.CreationTimestamp
+	out.ExecutionTimestamp = in.ExecutionTimestamp
+	out.ExecutedBy = in.ExecutedBy
+}
+
+type outputSecureAggregation struct {
+	Key               string                                 `json:"key"`
+	AggregatetupleKey string                                 `json:"aggregatetuple_key"`
+	Aggregator        string                                 `json:"aggregator"`
+	Participants      []string                               `json:"participants"`
+	Active            []string                               `json:"active"`
+	Threshold         int                                    `json:"threshold"`
+	Phase             string                                 `json:"phase"`
+	PublicKeys        map[string]string                      `json:"public_keys"`
+	EncryptedShares   map[string]map[string]string           `json:"encrypted_shares"`
+	Commitments       map[string]SecureAggregationCommitment `json:"commitments"`
+	RecoveryShares    map[string]map[string]string           `json:"recovery_shares"`
+}
+
+func (out *outputSecureAggregation) Fill(in SecureAggregation) {
+	out.Key = in.Key
+	out.AggregatetupleKey = in.AggregatetupleKey
+	out.Aggregator = in.Aggregator
+	out.Participants = in.Participants
+	out.Active = in.Active
+	out.Threshold = in.Threshold
+	out.Phase = in.Phase
+	out.PublicKeys = in.PublicKeys
+	out.EncryptedShares = in.EncryptedShares
+	out.Commitments = in.Commitments
+	out.RecoveryShares = in.RecoveryShares
+}
+
+type outputChannelConfig struct {
+	PageSize                int32    `json:"page_size"`
+	MaxComputePlanSize      int      `json:"max_compute_plan_size"`
+	MaxLogLength            int      `json:"max_log_length"`
+	LogRetention            int      `json:"log_retention"`
+	MaxMetadataItems        int      `json:"max_metadata_items"`
+	AllowedDataManagerTypes []string `json:"allowed_data_manager_types"`
+}
+
+func (out *outputChannelConfig) Fill(in ChannelConfig) {
+	*out = outputChannelConfig(in)
+}
+
+type outputNodeQuotas struct {
+	NodeID   string         `json:"node_id"`
+	Quotas   NodeQuotas     `json:"quotas"`
+	Counters WorkerCounters `json:"counters"`
+}
+
+func (out *outputNodeQuotas) Fill(node Node, counters WorkerCounters) {
+	out.NodeID = node.ID
+	out.Quotas = node.Quotas
+	out.Counters = counters
+}
 
-func (out outputBoardTuples) Len() int {
-	return len(out)
+type outputNodeGroup struct {
+	Key     string   `json:"key"`
+	Name    string   `json:"name"`
+	Owner   string   `json:"owner"`
+	NodeIDs []string `json:"node_ids"`
 }
 
-func (out outputBoardTuples) Swap(i, j int) {
-	out[i], out[j] = out[j], out[i]
+func (out *outputNodeGroup) Fill(in NodeGroup) {
+	out.Key = in.Key
+	out.Name = in.Name
+	out.Owner = in.Owner
+	out.NodeIDs = in.NodeIDs
 }
 
-func (out outputBoardTuples) Less(i, j int) bool {
-	return out[i].Perf < out[j].Perf
+type outputDownloadPermission struct {
+	Key        string `json:"key"`
+	NodeID     string `json:"node_id"`
+	Owner      string `json:"owner"`
+	Authorized bool   `json:"authorized"`
 }
 
+type outputLeaderboard struct {
+	Objective  outputObjective   `json:"objective"`
+	Testtuples outputBoardTuples `json:"testtuples"`
+
+	Bookmark string `json:"bookmark"`
+}
+
+type outputBoardTuples []outputBoardTuple
+
 type outputBoardTuple struct {
-	Algo          *KeyChecksumAddressName `json:"algo"`
-	Creator       string                  `json:"creator"`
-	Key           string                  `json:"key"`
-	TraintupleKey string                  `json:"traintuple_key"`
-	Perf          float32                 `json:"perf"`
-	Tag           string                  `json:"tag"`
+	Algo           *KeyChecksumAddressName `json:"algo"`
+	AlgoFamilyKey  string                  `json:"algo_family_key"`
+	AlgoOwner      string                  `json:"algo_owner"`
+	ComputePlanKey string                  `json:"compute_plan_key"`
+	Creator        string                  `json:"creator"`
+	DoneTimestamp  int64                   `json:"done_timestamp"`
+	Key            string                  `json:"key"`
+	TraintupleKey  string                  `json:"traintuple_key"`
+	Perf           float32                 `json:"perf"`
+	Perfs          map[string]float32      `json:"perfs"`
+	Tag            string                  `json:"tag"`
 }
 
 func (out *outputBoardTuple) Fill(db *LedgerDB, in Testtuple, testtupleKey string) error {
 	out.Key = testtupleKey
 	out.Creator = in.Creator
-	algo, err := db.GetAlgo(in.AlgoKey)
+	algo, err := db.GetAlgoOfAnyType(in.AlgoKey)
 	if err != nil {
 		return err
 	}
@@ -439,8 +724,13 @@ func (out *outputBoardTuple) Fill(db *LedgerDB, in Testtuple, testtupleKey strin
 		Checksum:       algo.Checksum,
 		StorageAddress: algo.StorageAddress,
 	}
+	out.AlgoFamilyKey = algo.GetFamilyKey()
+	out.AlgoOwner = algo.Owner
+	out.ComputePlanKey = in.ComputePlanKey
+	out.DoneTimestamp = in.DoneTimestamp
 	out.TraintupleKey = in.TraintupleKey
 	out.Perf = in.Dataset.Perf
+	out.Perfs = in.Dataset.Perfs
 	out.Tag = in.Tag
 
 	return nil
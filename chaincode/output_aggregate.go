@@ -28,8 +28,11 @@ type outputAggregatetuple struct {
 	Rank           int                     `json:"rank"`
 	Status         string                  `json:"status"`
 	Tag            string                  `json:"tag"`
-	Permissions    outputPermissions       `json:"permissions"`
+	Permissions    outputPermissionsFull   `json:"permissions"`
 	Worker         string                  `json:"worker"`
+
+	PermissionsRule      string `json:"permissions_rule"`
+	SecureAggregationKey string `json:"secure_aggregation_key"`
 }
 
 type outputAggregateAlgo struct {
@@ -85,6 +88,8 @@ func (outputAggregatetuple *outputAggregatetuple) Fill(db *LedgerDB, traintuple
 
 	outputAggregatetuple.Worker = traintuple.Worker
 	outputAggregatetuple.Permissions.Fill(traintuple.Permissions)
+	outputAggregatetuple.PermissionsRule = traintuple.GetPermissionsRule()
+	outputAggregatetuple.SecureAggregationKey = traintuple.SecureAggregationKey
 
 	return
 }
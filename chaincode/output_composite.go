@@ -38,13 +38,13 @@ type outputCompositeTraintuple struct {
 }
 
 type outHeadModelComposite struct {
-	OutModel    *KeyChecksum      `json:"out_model"`
-	Permissions outputPermissions `json:"permissions"`
+	OutModel    *KeyChecksum          `json:"out_model"`
+	Permissions outputPermissionsFull `json:"permissions"`
 }
 
 type outModelComposite struct {
-	OutModel    *KeyChecksumAddress `json:"out_model"`
-	Permissions outputPermissions   `json:"permissions"`
+	OutModel    *KeyChecksumAddress   `json:"out_model"`
+	Permissions outputPermissionsFull `json:"permissions"`
 }
 
 //Fill is a method of the receiver outputCompositeTraintuple. It returns all elements necessary to do a training task from a trainuple stored in the ledger
@@ -132,8 +132,8 @@ func (outputCompositeTraintuple *outputCompositeTraintuple) Fill(db *LedgerDB, t
 	return
 }
 
-func getOutPermissions(in Permissions) (out outputPermissions) {
-	out = outputPermissions{}
+func getOutPermissions(in Permissions) (out outputPermissionsFull) {
+	out = outputPermissionsFull{}
 	out.Fill(in)
 	return out
 }
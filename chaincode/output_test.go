@@ -21,26 +21,35 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func TestLeaderboardSort(t *testing.T) {
-	unOrderedPerf := []float64{0.3, 0.5, 0.2, 0.9, 0.4}
-	boardTuples := outputBoardTuples{}
+func TestLeaderboardSortKey(t *testing.T) {
+	unOrderedPerf := []float64{0.3, -0.5, 0.2, 0.9, -0.4, 0}
+	ascendingKeys := []string{}
+	descendingKeys := []string{}
 	for _, v := range unOrderedPerf {
-		boardTuples = append(boardTuples, outputBoardTuple{Perf: float32(v)})
+		ascendingKeys = append(ascendingKeys, getLeaderboardSortKey(float32(v), true))
+		descendingKeys = append(descendingKeys, getLeaderboardSortKey(float32(v), false))
 	}
 
-	// Ascending order
-	sort.Sort(boardTuples)
+	// The lexicographic order of the keys is the order of the perfs
+	sort.Strings(ascendingKeys)
+	sort.Strings(descendingKeys)
 	sort.Float64s(unOrderedPerf)
-	for i, boardTuple := range boardTuples {
-		assert.EqualValues(t, float32(unOrderedPerf[i]), boardTuple.Perf)
+	for i, v := range unOrderedPerf {
+		assert.Equal(t, getLeaderboardSortKey(float32(v), true), ascendingKeys[i])
+		assert.Equal(t, getLeaderboardSortKey(float32(v), false), descendingKeys[len(descendingKeys)-1-i])
 	}
+}
 
-	// Reverse order
-	sort.Sort(sort.Reverse(boardTuples))
-	for i, j := 0, len(unOrderedPerf)-1; i < j; i, j = i+1, j-1 {
-		unOrderedPerf[i], unOrderedPerf[j] = unOrderedPerf[j], unOrderedPerf[i]
-	}
-	for i, boardTuple := range boardTuples {
-		assert.EqualValues(t, float32(unOrderedPerf[i]), boardTuple.Perf)
+func TestOutputObjectiveCopiesPreviousTestDatasets(t *testing.T) {
+	objective := Objective{
+		PreviousTestDatasets: []*Dataset{{DataSampleKeys: []string{"sample"}, Metadata: map[string]string{"k": "v"}}},
 	}
+	var out outputObjective
+	out.Fill(objective)
+	out.PreviousTestDatasets[0].DataSampleKeys[0] = "other"
+	out.PreviousTestDatasets[0].Metadata["k"] = "other"
+	out.PreviousTestDatasets[0].Worker = "other"
+	assert.Equal(t, []string{"sample"}, objective.PreviousTestDatasets[0].DataSampleKeys)
+	assert.Equal(t, map[string]string{"k": "v"}, objective.PreviousTestDatasets[0].Metadata)
+	assert.Empty(t, objective.PreviousTestDatasets[0].Worker)
 }
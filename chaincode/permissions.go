@@ -14,7 +14,10 @@
 
 package main
 
-import "chaincode/errors"
+import (
+	"chaincode/errors"
+	"fmt"
+)
 
 // Permission represents one permission based on an action type
 type Permission struct {
@@ -23,6 +26,9 @@ type Permission struct {
 	Public bool `json:"public"`
 	// AuthorizedIDs list all authorised nodes other than the asset's owner
 	AuthorizedIDs []string `json:"authorized_ids"`
+	// AuthorizedGroups list the keys of node groups whose members are authorised.
+	// Groups are expanded when the permission is evaluated.
+	AuthorizedGroups []string `json:"authorized_groups"`
 }
 
 // Permissions represents all permissions associated with an asset
@@ -33,29 +39,99 @@ type Permissions struct {
 	Process Permission `json:"process"`
 }
 
+// PermissionsChange records an update of the permissions of an asset by its owner
+type PermissionsChange struct {
+	Timestamp   int64       `json:"timestamp"`
+	Previous    Permissions `json:"previous"`
+	Permissions Permissions `json:"permissions"`
+}
+
 // CanProcess checks if a node can process the asset with the current permissions
-func (perms Permissions) CanProcess(owner, node string) bool {
+func (perms Permissions) CanProcess(db *LedgerDB, owner, node string) (bool, error) {
 	if owner == node {
-		return true
+		return true, nil
+	}
+	process, err := perms.Process.expandGroups(db)
+	if err != nil {
+		return false, err
 	}
 
-	if perms.Process.Public {
-		return true
+	if process.Public {
+		return true, nil
 	}
 
-	for _, authorizedNode := range perms.Process.AuthorizedIDs {
+	for _, authorizedNode := range process.AuthorizedIDs {
 		if node == authorizedNode {
-			return true
+			return true, nil
 		}
 	}
-	return false
+	return false, nil
 }
 
-// NewPermissions create the Permissions according to the arg received
+// CanDownload checks if a node can download the asset with the current permissions
+func (perms Permissions) CanDownload(db *LedgerDB, owner, node string) (bool, error) {
+	if owner == node {
+		return true, nil
+	}
+	download, err := perms.Download.expandGroups(db)
+	if err != nil {
+		return false, err
+	}
+	return download.Public || stringInSlice(node, download.AuthorizedIDs), nil
+}
+
+// expandGroups returns the permissions with the current members of their
+// authorized groups added to their authorized IDs
+func (perms Permissions) expandGroups(db *LedgerDB) (Permissions, error) {
+	process, err := perms.Process.expandGroups(db)
+	if err != nil {
+		return Permissions{}, err
+	}
+	download, err := perms.Download.expandGroups(db)
+	if err != nil {
+		return Permissions{}, err
+	}
+	return Permissions{Process: process, Download: download}, nil
+}
+
+func (priv Permission) expandGroups(db *LedgerDB) (Permission, error) {
+	if len(priv.AuthorizedGroups) == 0 {
+		return priv, nil
+	}
+	expanded := Permission{Public: priv.Public}
+	if priv.Public {
+		return expanded, nil
+	}
+	expanded.AuthorizedIDs = append(expanded.AuthorizedIDs, priv.AuthorizedIDs...)
+	for _, groupKey := range priv.AuthorizedGroups {
+		group, err := db.GetNodeGroup(groupKey)
+		if err != nil {
+			return Permission{}, err
+		}
+		for _, nodeID := range group.NodeIDs {
+			if !stringInSlice(nodeID, expanded.AuthorizedIDs) {
+				expanded.AuthorizedIDs = append(expanded.AuthorizedIDs, nodeID)
+			}
+		}
+	}
+	return expanded, nil
+}
+
+// NewPermissions create the Permissions according to the arg received.
+// The download permission is the process one if it is not specified.
 func NewPermissions(db *LedgerDB, in inputPermissions) (Permissions, error) {
-	if !in.Process.Public {
-		if err := validateAuthorizedIds(db, in.Process.AuthorizedIDs); err != nil {
-			return Permissions{}, err
+	download := in.Process
+	if in.Download != nil {
+		download = *in.Download
+	}
+	for _, permission := range []inputPermission{in.Process, download} {
+		if !permission.Public {
+			if err := validateAuthorizedIds(db, permission.AuthorizedIDs); err != nil {
+				return Permissions{}, err
+			}
+			if err := validateAuthorizedGroups(db, permission.AuthorizedGroups); err != nil {
+				return Permissions{}, err
+			}
 		}
 	}
 
@@ -65,10 +141,8 @@ func NewPermissions(db *LedgerDB, in inputPermissions) (Permissions, error) {
 	}
 
 	permissions := Permissions{}
-	process := newPermission(in.Process, owner)
-	permissions.Process = process
-	// Download permission is not implemented in the node server, so it is set to the process permission
-	permissions.Download = process
+	permissions.Process = newPermission(in.Process, owner)
+	permissions.Download = newPermission(download, owner)
 	return permissions, nil
 }
 
@@ -96,12 +170,21 @@ func (priv Permission) include(other Permission) bool {
 	return true
 }
 
-// MergePermissions returns the intersection of input permissions
-func MergePermissions(x, y Permissions) Permissions {
+// MergePermissions returns the intersection of input permissions.
+// Node groups are expanded to their current members.
+func MergePermissions(db *LedgerDB, x, y Permissions) (Permissions, error) {
+	x, err := x.expandGroups(db)
+	if err != nil {
+		return Permissions{}, err
+	}
+	y, err = y.expandGroups(db)
+	if err != nil {
+		return Permissions{}, err
+	}
 	perm := Permissions{}
 	perm.Process = mergePermissions(x.Process, y.Process)
 	perm.Download = mergePermissions(x.Download, y.Download)
-	return perm
+	return perm, nil
 }
 
 func mergePermissions(x, y Permission) Permission {
@@ -119,12 +202,21 @@ func mergePermissions(x, y Permission) Permission {
 	return priv
 }
 
-// UnionPermissions returns the union between two sets of permissions
-func UnionPermissions(x, y Permissions) Permissions {
+// UnionPermissions returns the union between two sets of permissions.
+// Node groups are expanded to their current members.
+func UnionPermissions(db *LedgerDB, x, y Permissions) (Permissions, error) {
+	x, err := x.expandGroups(db)
+	if err != nil {
+		return Permissions{}, err
+	}
+	y, err = y.expandGroups(db)
+	if err != nil {
+		return Permissions{}, err
+	}
 	perm := Permissions{}
 	perm.Process = unionPermissions(x.Process, y.Process)
 	perm.Download = unionPermissions(x.Download, y.Download)
-	return perm
+	return perm, nil
 }
 
 func unionPermissions(x, y Permission) Permission {
@@ -165,7 +257,8 @@ func (priv Permission) getNodesIntersection(p Permission) []string {
 	return nodes
 }
 
-// validateAuthorizedIds will return an error if one of the provided IDs is not a valid node
+// validateAuthorizedIds will return an error if one of the provided IDs is not an active node.
+// Suspended and removed nodes cannot be authorized.
 func validateAuthorizedIds(db *LedgerDB, IDs []string) error {
 	nodes, err := queryNodes(db, []string{})
 	if err != nil {
@@ -174,16 +267,251 @@ func validateAuthorizedIds(db *LedgerDB, IDs []string) error {
 
 	nodesIDs := []string{}
 	for _, node := range nodes {
-		nodesIDs = append(nodesIDs, node.ID)
+		if node.GetStatus() == NodeStatusActive {
+			nodesIDs = append(nodesIDs, node.ID)
+		}
 	}
 
-	// Validate Process inputPermissions
-	// @TODO Validate Download inputPermissions when implemented
 	for _, authorizedID := range IDs {
 		if !stringInSlice(authorizedID, nodesIDs) {
-			return errors.BadRequest("invalid permission input values")
+			return errors.BadRequest("invalid permission input values: %s is not an active node", authorizedID)
 		}
 	}
 
 	return nil
 }
+
+// validateAuthorizedGroups will return an error if one of the provided keys is not a node group
+func validateAuthorizedGroups(db *LedgerDB, keys []string) error {
+	for _, key := range keys {
+		if _, err := db.GetNodeGroup(key); err != nil {
+			return errors.BadRequest(err, "invalid permission input values: unknown node group %s", key)
+		}
+	}
+	return nil
+}
+
+// updateAssetPermissions replaces the permissions of an objective, a dataManager,
+// an algo of any type or a composite traintuple trunk model. Tuples keep the
+// permissions they were created with, so revoking a node does not affect them.
+func updateAssetPermissions(db *LedgerDB, args []string) (resp outputKey, err error) {
+	inp := inputUpdateAssetPermissions{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	permissions, err := NewPermissions(db, inp.Permissions)
+	if err != nil {
+		return
+	}
+	timestamp, err := db.GetTxTimestamp()
+	if err != nil {
+		return
+	}
+
+	modelTupleKeys, err := db.GetIndexKeys("tuple~modelKey~key", []string{"tuple", inp.Key})
+	if err != nil {
+		return
+	}
+	if len(modelTupleKeys) > 0 {
+		var tuple CompositeTraintuple
+		tuple, err = db.GetCompositeTraintuple(modelTupleKeys[0])
+		if err != nil || tuple.OutTrunkModel.OutModel == nil || tuple.OutTrunkModel.OutModel.Key != inp.Key {
+			err = errors.BadRequest("only the permissions of composite traintuple trunk models can be updated")
+			return
+		}
+		var dataManager DataManager
+		dataManager, err = db.GetDataManager(tuple.Dataset.DataManagerKey)
+		if err != nil {
+			return
+		}
+		if err = dataManager.UsagePolicy.checkModelPermissions(dataManager.Key, permissions); err != nil {
+			return
+		}
+		outModel := &tuple.OutTrunkModel
+		if err = changePermissions(db, inp.Key, "model", tuple.Dataset.Worker, &outModel.Permissions, permissions, timestamp); err != nil {
+			return
+		}
+		if err = db.Put(tuple.Key, tuple); err != nil {
+			return
+		}
+		return outputKey{Key: inp.Key}, nil
+	}
+
+	assetType, err := db.GetAssetType(inp.Key)
+	if err != nil {
+		return
+	}
+	var asset interface{}
+	switch assetType {
+	case ObjectiveType:
+		var objective Objective
+		if objective, err = db.GetObjective(inp.Key); err != nil {
+			return
+		}
+		err = changePermissions(db, inp.Key, assetType.String(), objective.Owner, &objective.Permissions, permissions, timestamp)
+		asset = objective
+	case DataManagerType:
+		var dataManager DataManager
+		if dataManager, err = db.GetDataManager(inp.Key); err != nil {
+			return
+		}
+		err = changePermissions(db, inp.Key, assetType.String(), dataManager.Owner, &dataManager.Permissions, permissions, timestamp)
+		asset = dataManager
+	case AlgoType, CompositeAlgoType, AggregateAlgoType:
+		var algo Algo
+		if algo, err = db.GetAlgoOfAnyType(inp.Key); err != nil {
+			return
+		}
+		err = changePermissions(db, inp.Key, assetType.String(), algo.Owner, &algo.Permissions, permissions, timestamp)
+		asset = algo
+	default:
+		err = errors.BadRequest("the permissions of asset %s of type %s cannot be updated", inp.Key, assetType)
+	}
+	if err != nil {
+		return
+	}
+	if err = db.Put(inp.Key, asset); err != nil {
+		return
+	}
+	return outputKey{Key: inp.Key}, nil
+}
+
+// queryPermissionsHistory returns the permissions changes of an asset, from the oldest to the latest one
+func queryPermissionsHistory(db *LedgerDB, args []string) (history []PermissionsChange, err error) {
+	inp := inputKey{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	history = []PermissionsChange{}
+	changeKeys, err := db.GetIndexKeys("permissionsChange~asset~timestamp~key", []string{"permissionsChange", inp.Key})
+	if err != nil {
+		return
+	}
+	if len(changeKeys) == 0 {
+		// the history of an unknown asset is an error, not an empty list
+		_, _, err = getAssetPermissions(db, inp.Key)
+		return
+	}
+	for _, changeKey := range changeKeys {
+		var change PermissionsChange
+		if err = db.Get(changeKey, &change); err != nil {
+			return
+		}
+		history = append(history, change)
+	}
+	return
+}
+
+// checkDownloadPermission tells if a node is allowed to download the files of an asset.
+// The asset can be an objective, a dataManager, an algo of any type or a model.
+func checkDownloadPermission(db *LedgerDB, args []string) (out outputDownloadPermission, err error) {
+	inp := inputCheckDownloadPermission{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	permissions, owner, err := getAssetPermissions(db, inp.Key)
+	if err != nil {
+		return
+	}
+	out.Key = inp.Key
+	out.NodeID = inp.NodeID
+	out.Owner = owner
+	out.Authorized, err = permissions.CanDownload(db, owner, inp.NodeID)
+	return
+}
+
+// getAssetPermissions returns the permissions and the owner of an asset which has files
+func getAssetPermissions(db *LedgerDB, key string) (Permissions, string, error) {
+	modelTupleKeys, err := db.GetIndexKeys("tuple~modelKey~key", []string{"tuple", key})
+	if err != nil {
+		return Permissions{}, "", err
+	}
+	if len(modelTupleKeys) > 0 {
+		model, err := getOutputModel(db, key)
+		if err != nil {
+			return Permissions{}, "", err
+		}
+		permissions := Permissions{Process: model.Permissions.Process, Download: model.Permissions.Download}
+		return permissions, model.Owner, nil
+	}
+
+	assetType, err := db.GetAssetType(key)
+	if err != nil {
+		return Permissions{}, "", err
+	}
+	switch assetType {
+	case ObjectiveType:
+		objective, err := db.GetObjective(key)
+		return objective.Permissions, objective.Owner, err
+	case DataManagerType:
+		dataManager, err := db.GetDataManager(key)
+		return dataManager.Permissions, dataManager.Owner, err
+	case AlgoType, CompositeAlgoType, AggregateAlgoType:
+		algo, err := db.GetAlgoOfAnyType(key)
+		return algo.Permissions, algo.Owner, err
+	}
+	return Permissions{}, "", errors.BadRequest("asset %s of type %s has no files to download", key, assetType)
+}
+
+// changePermissions checks that the transaction creator owns the asset, then replaces its permissions,
+// records the change in its history and notifies the nodes which were granted or revoked.
+func changePermissions(db *LedgerDB, key, assetType, owner string, current *Permissions, permissions Permissions, timestamp int64) error {
+	txCreator, err := GetTxCreator(db.cc)
+	if err != nil {
+		return err
+	}
+	if txCreator != owner {
+		return errors.Forbidden("%s is not the owner of the asset %s", txCreator, key)
+	}
+	nodes, err := queryNodes(db, []string{})
+	if err != nil {
+		return err
+	}
+	nodeIDs := []string{}
+	for _, node := range nodes {
+		nodeIDs = append(nodeIDs, node.ID)
+	}
+	previous, err := current.expandGroups(db)
+	if err != nil {
+		return err
+	}
+	next, err := permissions.expandGroups(db)
+	if err != nil {
+		return err
+	}
+	change := eventPermissionsChange{
+		Key:       key,
+		AssetType: assetType,
+		Process:   newEventPermissionChange(previous.Process, next.Process, nodeIDs),
+		Download:  newEventPermissionChange(previous.Download, next.Download, nodeIDs),
+	}
+	// each change is stored under its own key so that the asset does not grow with its history
+	changeKey := GetRandomHash()
+	if err = db.Add(changeKey, PermissionsChange{Timestamp: timestamp, Previous: *current, Permissions: permissions}); err != nil {
+		return err
+	}
+	if err = db.CreateIndex("permissionsChange~asset~timestamp~key", []string{"permissionsChange", key, fmt.Sprintf("%020d", timestamp), changeKey}); err != nil {
+		return err
+	}
+	*current = permissions
+	db.AddPermissionsChangeEvent(change)
+	return nil
+}
+
+func newEventPermissionChange(previous, next Permission, nodeIDs []string) eventPermissionChange {
+	change := eventPermissionChange{Granted: []string{}, Revoked: []string{}}
+	for _, nodeID := range nodeIDs {
+		before := previous.Public || stringInSlice(nodeID, previous.AuthorizedIDs)
+		after := next.Public || stringInSlice(nodeID, next.AuthorizedIDs)
+		switch {
+		case after && !before:
+			change.Granted = append(change.Granted, nodeID)
+		case before && !after:
+			change.Revoked = append(change.Revoked, nodeID)
+		}
+	}
+	return change
+}
@@ -18,6 +18,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -32,6 +33,10 @@ var (
 )
 
 func TestPermissionsCanProcess(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStub("substra", scc)
+	mockStub.MockTransactionStart("42")
+	db := NewLedgerDB(mockStub)
 	perms := defaultPermissions
 
 	testTable := []struct {
@@ -51,7 +56,8 @@ func TestPermissionsCanProcess(t *testing.T) {
 			perms.Process.Public = test.public
 			perms.Process.AuthorizedIDs = test.authorizedIDs
 
-			access := perms.CanProcess(defaultOwner, test.node)
+			access, err := perms.CanProcess(db, defaultOwner, test.node)
+			assert.NoError(t, err)
 			assert.Equal(t, test.expectedAccess, access)
 		})
 	}
@@ -119,3 +125,128 @@ func TestMergingMechanism(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckDownloadPermission(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	registerItem(t, *mockStub, "traintuple")
+	mockStub.MockTransactionStart("42")
+	db := NewLedgerDB(mockStub)
+	traintupleToDone(t, db, traintupleKey)
+
+	// an algo processable by everyone but only downloadable by its owner
+	inpAlgo := inputAlgo{Key: RandomUUID()}
+	inpAlgo.fillDefaults()
+	inpAlgo.Permissions.Download = &inputPermission{Public: false, AuthorizedIDs: []string{"unknown node"}}
+	_, err := registerAlgo(db, assetToArgs(inpAlgo))
+	assert.Error(t, err, "download permissions should only authorize registered nodes")
+	inpAlgo.Permissions.Download = &inputPermission{Public: false, AuthorizedIDs: []string{}}
+	_, err = registerAlgo(db, assetToArgs(inpAlgo))
+	require.NoError(t, err)
+
+	algo, err := queryAlgo(db, keyToArgs(inpAlgo.Key))
+	require.NoError(t, err)
+	assert.True(t, algo.Permissions.Process.Public)
+	assert.False(t, algo.Permissions.Download.Public)
+	assert.Equal(t, []string{workerA}, algo.Permissions.Download.AuthorizedIDs)
+
+	testTable := []struct {
+		name       string
+		key        string
+		node       string
+		authorized bool
+	}{
+		{"owner can download its algo", inpAlgo.Key, workerA, true},
+		{"other nodes cannot download a private algo", inpAlgo.Key, workerB, false},
+		{"download permission defaults to process", algoKey, workerB, true},
+		{"public dataManager", dataManagerKey, workerB, true},
+		{"public objective", objectiveKey, workerB, true},
+		{"model", modelKey, workerB, true},
+	}
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			inp := inputCheckDownloadPermission{Key: test.key, NodeID: test.node}
+			out, err := checkDownloadPermission(db, assetToArgs(inp))
+			require.NoError(t, err)
+			assert.Equal(t, test.authorized, out.Authorized)
+		})
+	}
+
+	_, err = checkDownloadPermission(db, assetToArgs(inputCheckDownloadPermission{Key: traintupleKey, NodeID: workerB}))
+	assert.Error(t, err, "traintuples have no files to download")
+}
+
+func TestUpdateAssetPermissions(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	registerWorker(mockStub, workerB)
+	registerWorker(mockStub, workerC)
+	registerItem(t, *mockStub, "compositeTraintuple")
+	mockStub.MockTransactionStart("42")
+	db := NewLedgerDB(mockStub)
+
+	inp := inputUpdateAssetPermissions{
+		Key: algoKey,
+		Permissions: inputPermissions{
+			Process: inputPermission{Public: false, AuthorizedIDs: []string{workerB}},
+		},
+	}
+	mockStub.Creator = workerB
+	_, err := updateAssetPermissions(db, assetToArgs(inp))
+	assert.Error(t, err, "only the owner can update the permissions of an asset")
+	mockStub.Creator = workerA
+
+	clearEvent(db)
+	_, err = updateAssetPermissions(db, assetToArgs(inp))
+	require.NoError(t, err)
+	algo, err := queryAlgo(db, keyToArgs(algoKey))
+	require.NoError(t, err)
+	assert.False(t, algo.Permissions.Process.Public)
+	assert.Equal(t, []string{workerA, workerB}, algo.Permissions.Process.AuthorizedIDs)
+
+	// the revoked node is notified
+	require.Len(t, db.event.PermissionsChanges, 1)
+	change := db.event.PermissionsChanges[0]
+	assert.Equal(t, algoKey, change.Key)
+	assert.Equal(t, []string{workerC}, change.Process.Revoked)
+	assert.Equal(t, []string{}, change.Process.Granted)
+
+	history, err := queryPermissionsHistory(db, keyToArgs(algoKey))
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.True(t, history[0].Previous.Process.Public)
+	assert.False(t, history[0].Permissions.Process.Public)
+	var stored map[string]interface{}
+	require.NoError(t, db.Get(algoKey, &stored))
+	assert.NotContains(t, stored, "permissions_history", "the history is not stored in the asset")
+	_, err = queryPermissionsHistory(db, keyToArgs(RandomUUID()))
+	assert.Error(t, err)
+
+	// existing tuples are not affected, new ones are
+	traintuple, err := queryTraintuple(db, keyToArgs(traintupleKey))
+	require.NoError(t, err)
+	assert.True(t, traintuple.Permissions.Process.Public)
+	mockStub.Creator = workerC
+	newTraintuple := inputTraintuple{Key: RandomUUID()}
+	newTraintuple.createDefault()
+	_, err = createTraintuple(db, assetToArgs(newTraintuple))
+	assert.Error(t, err, "the algo is no longer processable by the revoked node")
+	mockStub.Creator = workerA
+
+	// composite traintuple trunk models
+	headModelKey, trunkModelKey := RandomUUID(), RandomUUID()
+	compositeToDone(t, mockStub, workerA, db, compositeTraintupleKey, headModelKey, trunkModelKey)
+	inp.Key = trunkModelKey
+	_, err = updateAssetPermissions(db, assetToArgs(inp))
+	require.NoError(t, err)
+	model, err := queryModel(db, keyToArgs(trunkModelKey))
+	require.NoError(t, err)
+	assert.Equal(t, []string{workerA, workerB}, model.Permissions.Process.AuthorizedIDs)
+	history, err = queryPermissionsHistory(db, keyToArgs(trunkModelKey))
+	require.NoError(t, err)
+	assert.Len(t, history, 1)
+
+	inp.Key = headModelKey
+	_, err = updateAssetPermissions(db, assetToArgs(inp))
+	assert.Error(t, err, "head models stay private to their worker")
+}
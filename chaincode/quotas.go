@@ -0,0 +1,233 @@
+// Copyright 2018 Owkin, inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"chaincode/errors"
+)
+
+// secondsPerDay is used to reset the daily creation counters
+const secondsPerDay = 24 * 60 * 60
+
+// getWorkerCountersKey returns the ledger key of the tuple counters of a worker.
+// Counters are only kept for workers with quotas. Every tuple creation and
+// status change on such a worker writes this key, so concurrent transactions on
+// the same worker fail the MVCC validation of the peers and have to be
+// resubmitted by the client.
+func getWorkerCountersKey(worker string) string {
+	return "workerCounters_" + worker
+}
+
+// getWorkerCounters returns the tuple counters of a worker, with the daily
+// counters reset if they were last updated on a previous day
+func getWorkerCounters(db *LedgerDB, worker string) (WorkerCounters, error) {
+	timestamp, err := db.GetTxTimestamp()
+	if err != nil {
+		return WorkerCounters{}, err
+	}
+	day := timestamp / secondsPerDay
+	counters := WorkerCounters{}
+	key := getWorkerCountersKey(worker)
+	exists, err := db.KeyExists(key)
+	if err != nil {
+		return counters, err
+	}
+	if exists {
+		if err := db.Get(key, &counters); err != nil {
+			return counters, err
+		}
+	}
+	if counters.Worker == "" {
+		counters = WorkerCounters{
+			Worker:            worker,
+			PendingPerCreator: map[string]int{},
+			Day:               day,
+			CreatedPerCreator: map[string]int{},
+		}
+	}
+	if counters.Day != day {
+		counters.Day = day
+		counters.Created = 0
+		counters.CreatedPerCreator = map[string]int{}
+	}
+	return counters, nil
+}
+
+// getWorkerQuotas returns the quotas set by the node of a worker
+func getWorkerQuotas(db *LedgerDB, worker string) (NodeQuotas, error) {
+	node, err := db.GetNode(worker)
+	if err != nil {
+		return NodeQuotas{}, errors.BadRequest(err, "could not retrieve worker %s", worker)
+	}
+	return node.Quotas, nil
+}
+
+// checkWorkerQuotas returns an error if the creator cannot add a tuple on the
+// worker without exceeding its quotas. Quotas only protect a node from the
+// others: tuples created by the worker itself are counted but never limited.
+func checkWorkerQuotas(db *LedgerDB, worker, creator string) error {
+	return checkWorkerLimits(db, worker, creator, true)
+}
+
+// checkWorkerLimits returns an error if the creator exceeds the pending quotas
+// of the worker, and its daily quotas if daily is set
+func checkWorkerLimits(db *LedgerDB, worker, creator string, daily bool) error {
+	if worker == creator {
+		return nil
+	}
+	quotas, err := getWorkerQuotas(db, worker)
+	if err != nil {
+		return err
+	}
+	if quotas == (NodeQuotas{}) {
+		return nil
+	}
+	counters, err := getWorkerCounters(db, worker)
+	if err != nil {
+		return err
+	}
+	limits := []struct {
+		max   int
+		count int
+		name  string
+		daily bool
+	}{
+		{quotas.MaxPendingTuples, counters.Pending, "pending tuples", false},
+		{quotas.MaxPendingTuplesPerCreator, counters.PendingPerCreator[creator], "pending tuples per creator", false},
+		{quotas.MaxDailyTuples, counters.Created, "tuples created today", true},
+		{quotas.MaxDailyTuplesPerCreator, counters.CreatedPerCreator[creator], "tuples created today per creator", true},
+	}
+	for _, limit := range limits {
+		if limit.daily && !daily {
+			continue
+		}
+		if limit.max > 0 && limit.count >= limit.max {
+			return errors.Forbidden("quota of %d %s on worker %s reached", limit.max, limit.name, worker)
+		}
+	}
+	return nil
+}
+
+// countTupleCreation updates the counters of the worker for a new tuple
+func countTupleCreation(db *LedgerDB, worker, creator, status string) error {
+	quotas, err := getWorkerQuotas(db, worker)
+	if err != nil || quotas == (NodeQuotas{}) {
+		return err
+	}
+	counters, err := getWorkerCounters(db, worker)
+	if err != nil {
+		return err
+	}
+	counters.Created++
+	counters.CreatedPerCreator[creator]++
+	if isPendingStatus(status) {
+		counters.Pending++
+		counters.PendingPerCreator[creator]++
+	}
+	return db.Put(getWorkerCountersKey(worker), counters)
+}
+
+// countTupleStatusUpdate updates the pending counters of the worker when a
+// tuple enters or leaves the todo and doing status. A tuple created as waiting
+// is checked against the pending quotas when it becomes todo: the update of its
+// parent is refused until the worker has room for it.
+func countTupleStatusUpdate(db *LedgerDB, worker, creator, oldStatus, newStatus string) error {
+	if isPendingStatus(oldStatus) == isPendingStatus(newStatus) {
+		return nil
+	}
+	quotas, err := getWorkerQuotas(db, worker)
+	if err != nil || quotas == (NodeQuotas{}) {
+		return err
+	}
+	if oldStatus == StatusWaiting {
+		if err := checkWorkerLimits(db, worker, creator, false); err != nil {
+			return err
+		}
+	}
+	counters, err := getWorkerCounters(db, worker)
+	if err != nil {
+		return err
+	}
+	delta := 1
+	if isPendingStatus(oldStatus) {
+		delta = -1
+	}
+	// tuples created before the counters existed were never counted as pending
+	counters.Pending += delta
+	if counters.Pending < 0 {
+		counters.Pending = 0
+	}
+	counters.PendingPerCreator[creator] += delta
+	if counters.PendingPerCreator[creator] <= 0 {
+		delete(counters.PendingPerCreator, creator)
+	}
+	return db.Put(getWorkerCountersKey(worker), counters)
+}
+
+func isPendingStatus(status string) bool {
+	return status == StatusTodo || status == StatusDoing
+}
+
+// updateNodeQuotas replaces the quotas of the transaction creator's node
+func updateNodeQuotas(db *LedgerDB, args []string) (out outputNodeQuotas, err error) {
+	inp := inputNodeQuotas{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	txCreator, err := GetTxCreator(db.cc)
+	if err != nil {
+		return
+	}
+	node, err := db.GetNode(txCreator)
+	if err != nil {
+		return
+	}
+	// counters are not kept without quotas, start them over
+	if node.Quotas == (NodeQuotas{}) {
+		if err = db.Put(getWorkerCountersKey(node.ID), WorkerCounters{}); err != nil {
+			return
+		}
+	}
+	node.Quotas = NodeQuotas(inp)
+	if err = db.Put(node.ID, node); err != nil {
+		return
+	}
+	return getNodeQuotas(db, node)
+}
+
+// queryNodeQuotas returns the quotas of a node and its current counters
+func queryNodeQuotas(db *LedgerDB, args []string) (out outputNodeQuotas, err error) {
+	inp := inputNodeID{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	node, err := db.GetNode(inp.NodeID)
+	if err != nil {
+		return
+	}
+	return getNodeQuotas(db, node)
+}
+
+// getNodeQuotas returns the quotas of a node along with its current counters
+func getNodeQuotas(db *LedgerDB, node Node) (out outputNodeQuotas, err error) {
+	counters, err := getWorkerCounters(db, node.ID)
+	if err != nil {
+		return
+	}
+	out.Fill(node, counters)
+	return
+}
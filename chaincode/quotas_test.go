@@ -0,0 +1,136 @@
+// Copyright 2018 Owkin, inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeQuotas(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	registerWorker(mockStub, workerC)
+	registerItem(t, *mockStub, "traintuple")
+	mockStub.MockTransactionStart("42")
+	db := NewLedgerDB(mockStub)
+
+	_, err := updateNodeQuotas(db, assetToArgs(inputNodeQuotas{MaxPendingTuplesPerCreator: 1, MaxDailyTuples: 2}))
+	require.NoError(t, err)
+
+	createTraintuple := func(creator string) (string, error) {
+		mockStub.Creator = creator
+		defer func() { mockStub.Creator = workerA }()
+		inp := inputTraintuple{Key: RandomUUID()}
+		inp.createDefault()
+		out, err := createTraintuple(db, assetToArgs(inp))
+		return out.Key, err
+	}
+	failTraintuple := func(key string) {
+		_, err := logStartTrain(db, assetToArgs(inputKey{Key: key}))
+		require.NoError(t, err)
+		_, err = logFailTrain(db, assetToArgs(inputLogFailTrain{inputLog{Key: key}}))
+		require.NoError(t, err)
+	}
+
+	key, err := createTraintuple(workerC)
+	require.NoError(t, err)
+	_, err = createTraintuple(workerC)
+	assert.Error(t, err, "workerC already has a pending tuple on workerA")
+	_, err = createTraintuple(workerA)
+	assert.NoError(t, err, "the worker is not limited on its own node")
+
+	// the tuple registered before the quotas were set is not counted
+	quotas, err := queryNodeQuotas(db, assetToArgs(inputNodeID{NodeID: workerA}))
+	require.NoError(t, err)
+	assert.Equal(t, 2, quotas.Counters.Pending)
+	assert.Equal(t, 1, quotas.Counters.PendingPerCreator[workerC])
+	assert.Equal(t, 2, quotas.Counters.Created)
+
+	// finished tuples are not pending anymore, but the daily quota is reached
+	failTraintuple(key)
+	quotas, err = queryNodeQuotas(db, assetToArgs(inputNodeID{NodeID: workerA}))
+	require.NoError(t, err)
+	assert.Equal(t, 0, quotas.Counters.PendingPerCreator[workerC])
+	_, err = createTraintuple(workerC)
+	assert.Error(t, err, "the daily quota is reached")
+
+	mockStub.TxTimestamp.Seconds += secondsPerDay
+	_, err = createTraintuple(workerC)
+	assert.NoError(t, err, "the daily quota is reset the next day")
+}
+
+func TestNodeQuotasWaitingTuple(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	registerWorker(mockStub, workerC)
+	registerItem(t, *mockStub, "traintuple")
+	mockStub.MockTransactionStart("42")
+	db := NewLedgerDB(mockStub)
+
+	_, err := updateNodeQuotas(db, assetToArgs(inputNodeQuotas{MaxPendingTuplesPerCreator: 1}))
+	require.NoError(t, err)
+
+	// workerC queues a waiting tuple, then fills its pending quota
+	mockStub.Creator = workerC
+	child := inputTraintuple{Key: RandomUUID(), InModels: []string{traintupleKey}}
+	child.createDefault()
+	_, err = createTraintuple(db, assetToArgs(child))
+	require.NoError(t, err)
+	pending := inputTraintuple{Key: RandomUUID()}
+	pending.createDefault()
+	_, err = createTraintuple(db, assetToArgs(pending))
+	require.NoError(t, err)
+	mockStub.Creator = workerA
+	_, err = logStartTrain(db, assetToArgs(inputKey{Key: traintupleKey}))
+	require.NoError(t, err)
+	require.NoError(t, db.Flush())
+
+	success := inputLogSuccessTrain{}
+	success.Key = traintupleKey
+	success.fillDefaults()
+	_, err = logSuccessTrain(db, assetToArgs(success))
+	assert.Error(t, err, "the waiting tuple cannot become todo while workerC is at its quota")
+
+	db = NewLedgerDB(mockStub)
+	_, err = logStartTrain(db, assetToArgs(inputKey{Key: pending.Key}))
+	require.NoError(t, err)
+	_, err = logFailTrain(db, assetToArgs(inputLogFailTrain{inputLog{Key: pending.Key}}))
+	require.NoError(t, err)
+	_, err = logSuccessTrain(db, assetToArgs(success))
+	require.NoError(t, err)
+	traintuple, err := queryTraintuple(db, keyToArgs(child.Key))
+	require.NoError(t, err)
+	assert.Equal(t, StatusTodo, traintuple.Status)
+}
+
+func TestNodeQuotasUncountedTuple(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	mockStub.MockTransactionStart("42")
+	db := NewLedgerDB(mockStub)
+	_, err := updateNodeQuotas(db, assetToArgs(inputNodeQuotas{MaxPendingTuples: 1}))
+	require.NoError(t, err)
+
+	// a tuple created before the counters existed finishes
+	err = countTupleStatusUpdate(db, workerA, workerB, StatusDoing, StatusDone)
+	require.NoError(t, err)
+	quotas, err := queryNodeQuotas(db, assetToArgs(inputNodeID{NodeID: workerA}))
+	require.NoError(t, err)
+	assert.Equal(t, 0, quotas.Counters.Pending)
+	assert.Equal(t, 0, quotas.Counters.PendingPerCreator[workerB])
+}
@@ -0,0 +1,432 @@
+// Copyright 2018 Owkin, inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"chaincode/errors"
+	"sort"
+)
+
+// List of the phases of a secure aggregation, in order
+const (
+	SecureAggregationPhaseAdvertiseKeys = "advertise_keys"
+	SecureAggregationPhaseShareKeys     = "share_keys"
+	SecureAggregationPhaseMaskedInput   = "masked_input"
+	SecureAggregationPhaseUnmasking     = "unmasking"
+	SecureAggregationPhaseDone          = "done"
+	SecureAggregationPhaseAborted       = "aborted"
+)
+
+var secureAggregationNextPhase = map[string]string{
+	SecureAggregationPhaseAdvertiseKeys: SecureAggregationPhaseShareKeys,
+	SecureAggregationPhaseShareKeys:     SecureAggregationPhaseMaskedInput,
+	SecureAggregationPhaseMaskedInput:   SecureAggregationPhaseUnmasking,
+	SecureAggregationPhaseUnmasking:     SecureAggregationPhaseDone,
+}
+
+// -------------------------------------------------------------------------------------------
+// Methods on receivers SecureAggregation
+// -------------------------------------------------------------------------------------------
+
+// completed returns the active participants which completed the current phase
+func (secureAggregation *SecureAggregation) completed() []string {
+	nodes := []string{}
+	for _, node := range secureAggregation.Active {
+		var ok bool
+		switch secureAggregation.Phase {
+		case SecureAggregationPhaseAdvertiseKeys:
+			_, ok = secureAggregation.PublicKeys[node]
+		case SecureAggregationPhaseShareKeys:
+			_, ok = secureAggregation.EncryptedShares[node]
+		case SecureAggregationPhaseMaskedInput:
+			_, ok = secureAggregation.Commitments[node]
+		case SecureAggregationPhaseUnmasking:
+			_, ok = secureAggregation.RecoveryShares[node]
+		}
+		if ok {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// advance moves to the next phase, dropping the participants which didn't
+// complete the current one. The protocol aborts if less than Threshold remain.
+func (secureAggregation *SecureAggregation) advance() {
+	secureAggregation.Active = secureAggregation.completed()
+	if len(secureAggregation.Active) < secureAggregation.Threshold {
+		secureAggregation.Phase = SecureAggregationPhaseAborted
+		return
+	}
+	secureAggregation.Phase = secureAggregationNextPhase[secureAggregation.Phase]
+}
+
+// tryAdvance moves to the next phase once all the active participants completed
+// the current one. Unmasking only requires Threshold participants.
+func (secureAggregation *SecureAggregation) tryAdvance() {
+	completed := len(secureAggregation.completed())
+	if completed == len(secureAggregation.Active) ||
+		(secureAggregation.Phase == SecureAggregationPhaseUnmasking && completed >= secureAggregation.Threshold) {
+		secureAggregation.advance()
+	}
+}
+
+// checkSharesRecipients returns an error if shares are not addressed to
+// exactly the expected nodes
+func checkSharesRecipients(shares map[string]string, expected []string) error {
+	if len(shares) != len(expected) {
+		return errors.BadRequest("expected shares for %d nodes, got %d", len(expected), len(shares))
+	}
+	for _, node := range expected {
+		if _, ok := shares[node]; !ok {
+			return errors.BadRequest("missing share for node %s", node)
+		}
+	}
+	return nil
+}
+
+// getSecureAggregationParticipant returns the secure aggregation and its
+// participant submitting the transaction, checking it can act in the phase
+func getSecureAggregationParticipant(db *LedgerDB, key, phase string) (SecureAggregation, string, error) {
+	secureAggregation, err := db.GetSecureAggregation(key)
+	if err != nil {
+		return secureAggregation, "", err
+	}
+	if secureAggregation.Phase != phase {
+		return secureAggregation, "", errors.BadRequest("secure aggregation %s is in phase %s, not %s", key, secureAggregation.Phase, phase)
+	}
+	participant, err := GetTxCreator(db.cc)
+	if err != nil {
+		return secureAggregation, "", err
+	}
+	if !stringInSlice(participant, secureAggregation.Active) {
+		return secureAggregation, "", errors.Forbidden("%s is not an active participant of secure aggregation %s", participant, key)
+	}
+	return secureAggregation, participant, nil
+}
+
+// saveSecureAggregationPhase advances the secure aggregation if the phase is
+// completed, saves it and returns its output
+func saveSecureAggregationPhase(db *LedgerDB, secureAggregation SecureAggregation) (out outputSecureAggregation, err error) {
+	secureAggregation.tryAdvance()
+	return saveSecureAggregation(db, secureAggregation)
+}
+
+// saveSecureAggregation saves the secure aggregation and returns its output.
+// Once aborted, its aggregatetuple fails: it cannot aggregate the in-models in
+// the clear.
+func saveSecureAggregation(db *LedgerDB, secureAggregation SecureAggregation) (out outputSecureAggregation, err error) {
+	if err = db.Put(secureAggregation.Key, secureAggregation); err != nil {
+		return
+	}
+	if secureAggregation.Phase == SecureAggregationPhaseAborted {
+		if err = failSecureAggregationTuple(db, secureAggregation); err != nil {
+			return
+		}
+	}
+	out.Fill(secureAggregation)
+	return
+}
+
+// failSecureAggregationTuple fails the aggregatetuple of an aborted secure
+// aggregation and, outside of compute plans, its children
+func failSecureAggregationTuple(db *LedgerDB, secureAggregation SecureAggregation) error {
+	aggregatetuple, err := db.GetAggregatetuple(secureAggregation.AggregatetupleKey)
+	if err != nil {
+		return err
+	}
+	aggregatetuple.Log, err = appendLog(db, aggregatetuple.Log, "secure aggregation "+secureAggregation.Key+" aborted")
+	if err != nil {
+		return err
+	}
+	if err = aggregatetuple.commitStatusUpdate(db, aggregatetuple.Key, StatusFailed); err != nil {
+		return err
+	}
+	if aggregatetuple.ComputePlanKey != "" {
+		return nil
+	}
+	if err = UpdateTesttupleChildren(db, aggregatetuple.Key, aggregatetuple.Status); err != nil {
+		return err
+	}
+	return UpdateTraintupleChildren(db, aggregatetuple.Key, aggregatetuple.Status, []string{})
+}
+
+// checkSecureAggregationDone returns an error if the aggregatetuple runs a
+// secure aggregation which didn't complete yet
+func checkSecureAggregationDone(db *LedgerDB, aggregatetuple Aggregatetuple) error {
+	if aggregatetuple.SecureAggregationKey == "" {
+		return nil
+	}
+	secureAggregation, err := db.GetSecureAggregation(aggregatetuple.SecureAggregationKey)
+	if err != nil {
+		return err
+	}
+	if secureAggregation.Phase != SecureAggregationPhaseDone {
+		return errors.BadRequest("secure aggregation %s is in phase %s", secureAggregation.Key, secureAggregation.Phase)
+	}
+	return nil
+}
+
+// -------------------------------------------------------------------------------------------
+// Smart contracts related to secure aggregations
+// -------------------------------------------------------------------------------------------
+
+// createSecureAggregation starts a secure aggregation between the workers of the
+// in-models of an aggregatetuple which didn't start yet. Without an explicit
+// threshold, a majority of the participants is required to unmask the sum.
+func createSecureAggregation(db *LedgerDB, args []string) (out outputSecureAggregation, err error) {
+	inp := inputSecureAggregation{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	aggregatetuple, err := db.GetAggregatetuple(inp.AggregatetupleKey)
+	if err != nil {
+		return
+	}
+	creator, err := GetTxCreator(db.cc)
+	if err != nil {
+		return
+	}
+	if creator != aggregatetuple.Creator {
+		err = errors.Forbidden("only the creator of aggregatetuple %s can start a secure aggregation", aggregatetuple.Key)
+		return
+	}
+	if aggregatetuple.Status != StatusWaiting && aggregatetuple.Status != StatusTodo {
+		err = errors.BadRequest("aggregatetuple %s is %s", aggregatetuple.Key, aggregatetuple.Status)
+		return
+	}
+	if aggregatetuple.SecureAggregationKey != "" {
+		err = errors.Conflict("aggregatetuple %s already runs a secure aggregation", aggregatetuple.Key).WithKey(aggregatetuple.SecureAggregationKey)
+		return
+	}
+	exists, err := db.KeyExists(inp.Key)
+	if err != nil {
+		return
+	}
+	if exists {
+		err = errors.Conflict("secure aggregation already exists").WithKey(inp.Key)
+		return
+	}
+
+	participants := []string{}
+	for _, inModel := range aggregatetuple.InModelKeys {
+		parent, err := db.GetGenericTuple(inModel)
+		if err != nil {
+			return out, err
+		}
+		if !stringInSlice(parent.GetWorker(), participants) {
+			participants = append(participants, parent.GetWorker())
+		}
+	}
+	sort.Strings(participants)
+	if len(participants) < 2 {
+		err = errors.BadRequest("a secure aggregation requires at least 2 participants, aggregatetuple %s has %d", aggregatetuple.Key, len(participants))
+		return
+	}
+	threshold := inp.Threshold
+	if threshold == 0 {
+		threshold = len(participants)/2 + 1
+		if threshold < 2 {
+			threshold = 2
+		}
+	}
+	if threshold < 2 || threshold > len(participants) {
+		err = errors.BadRequest("threshold must be between 2 and the number of participants (%d)", len(participants))
+		return
+	}
+
+	secureAggregation := SecureAggregation{
+		Key:               inp.Key,
+		AssetType:         SecureAggregationType,
+		AggregatetupleKey: aggregatetuple.Key,
+		Aggregator:        aggregatetuple.Worker,
+		Participants:      participants,
+		Active:            participants,
+		Threshold:         threshold,
+		Phase:             SecureAggregationPhaseAdvertiseKeys,
+		PublicKeys:        map[string]string{},
+		EncryptedShares:   map[string]map[string]string{},
+		Commitments:       map[string]SecureAggregationCommitment{},
+		RecoveryShares:    map[string]map[string]string{},
+	}
+	if err = db.Add(secureAggregation.Key, secureAggregation); err != nil {
+		return
+	}
+	aggregatetuple.SecureAggregationKey = secureAggregation.Key
+	if err = db.Put(aggregatetuple.Key, aggregatetuple); err != nil {
+		return
+	}
+	out.Fill(secureAggregation)
+	return
+}
+
+// advertiseSecureAggregationKey registers the public key of a participant
+func advertiseSecureAggregationKey(db *LedgerDB, args []string) (out outputSecureAggregation, err error) {
+	inp := inputSecureAggregationKey{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	secureAggregation, participant, err := getSecureAggregationParticipant(db, inp.Key, SecureAggregationPhaseAdvertiseKeys)
+	if err != nil {
+		return
+	}
+	if _, ok := secureAggregation.PublicKeys[participant]; ok {
+		err = errors.Conflict("%s already advertised its key", participant)
+		return
+	}
+	secureAggregation.PublicKeys[participant] = inp.PublicKey
+	return saveSecureAggregationPhase(db, secureAggregation)
+}
+
+// shareSecureAggregationKeys registers the shares of a participant's secrets,
+// encrypted for each of the other active participants
+func shareSecureAggregationKeys(db *LedgerDB, args []string) (out outputSecureAggregation, err error) {
+	inp := inputSecureAggregationShares{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	secureAggregation, participant, err := getSecureAggregationParticipant(db, inp.Key, SecureAggregationPhaseShareKeys)
+	if err != nil {
+		return
+	}
+	if _, ok := secureAggregation.EncryptedShares[participant]; ok {
+		err = errors.Conflict("%s already shared its keys", participant)
+		return
+	}
+	recipients := []string{}
+	for _, node := range secureAggregation.Active {
+		if node != participant {
+			recipients = append(recipients, node)
+		}
+	}
+	if err = checkSharesRecipients(inp.Shares, recipients); err != nil {
+		return
+	}
+	secureAggregation.EncryptedShares[participant] = inp.Shares
+	return saveSecureAggregationPhase(db, secureAggregation)
+}
+
+// commitSecureAggregationMask attaches the commitment of a participant's mask
+// to the out-model of its in-model tuple, which must be done
+func commitSecureAggregationMask(db *LedgerDB, args []string) (out outputSecureAggregation, err error) {
+	inp := inputSecureAggregationCommitment{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	secureAggregation, participant, err := getSecureAggregationParticipant(db, inp.Key, SecureAggregationPhaseMaskedInput)
+	if err != nil {
+		return
+	}
+	if _, ok := secureAggregation.Commitments[participant]; ok {
+		err = errors.Conflict("%s already committed its mask", participant)
+		return
+	}
+	aggregatetuple, err := db.GetAggregatetuple(secureAggregation.AggregatetupleKey)
+	if err != nil {
+		return
+	}
+	if !stringInSlice(inp.TupleKey, aggregatetuple.InModelKeys) {
+		err = errors.BadRequest("tuple %s is not an in-model of aggregatetuple %s", inp.TupleKey, aggregatetuple.Key)
+		return
+	}
+	tuple, err := db.GetGenericTuple(inp.TupleKey)
+	if err != nil {
+		return
+	}
+	if tuple.GetWorker() != participant {
+		err = errors.Forbidden("tuple %s is not run by %s", inp.TupleKey, participant)
+		return
+	}
+	if tuple.Status != StatusDone {
+		err = errors.BadRequest("tuple %s is %s", inp.TupleKey, tuple.Status)
+		return
+	}
+	secureAggregation.Commitments[participant] = SecureAggregationCommitment{
+		TupleKey:   inp.TupleKey,
+		Commitment: inp.Commitment,
+	}
+	return saveSecureAggregationPhase(db, secureAggregation)
+}
+
+// revealSecureAggregationShares registers the shares a participant reveals to
+// unmask the sum: one for each other participant which shared its keys
+func revealSecureAggregationShares(db *LedgerDB, args []string) (out outputSecureAggregation, err error) {
+	inp := inputSecureAggregationShares{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	secureAggregation, participant, err := getSecureAggregationParticipant(db, inp.Key, SecureAggregationPhaseUnmasking)
+	if err != nil {
+		return
+	}
+	if _, ok := secureAggregation.RecoveryShares[participant]; ok {
+		err = errors.Conflict("%s already revealed its shares", participant)
+		return
+	}
+	subjects := []string{}
+	for _, node := range secureAggregation.Participants {
+		if _, ok := secureAggregation.EncryptedShares[node]; ok && node != participant {
+			subjects = append(subjects, node)
+		}
+	}
+	if err = checkSharesRecipients(inp.Shares, subjects); err != nil {
+		return
+	}
+	secureAggregation.RecoveryShares[participant] = inp.Shares
+	return saveSecureAggregationPhase(db, secureAggregation)
+}
+
+// advanceSecureAggregation lets the aggregator close the current phase,
+// dropping the participants which didn't complete it
+func advanceSecureAggregation(db *LedgerDB, args []string) (out outputSecureAggregation, err error) {
+	inp := inputKey{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	secureAggregation, err := db.GetSecureAggregation(inp.Key)
+	if err != nil {
+		return
+	}
+	if err = validateTupleOwner(db, secureAggregation.Aggregator); err != nil {
+		return
+	}
+	if _, ok := secureAggregationNextPhase[secureAggregation.Phase]; !ok {
+		err = errors.BadRequest("secure aggregation %s is %s", inp.Key, secureAggregation.Phase)
+		return
+	}
+	secureAggregation.advance()
+	return saveSecureAggregation(db, secureAggregation)
+}
+
+// querySecureAggregation returns a secure aggregation of the ledger given its key
+func querySecureAggregation(db *LedgerDB, args []string) (out outputSecureAggregation, err error) {
+	inp := inputKey{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+	secureAggregation, err := db.GetSecureAggregation(inp.Key)
+	if err != nil {
+		return
+	}
+	out.Fill(secureAggregation)
+	return
+}
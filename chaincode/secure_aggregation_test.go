@@ -0,0 +1,199 @@
+// Copyright 2018 Owkin, inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecureAggregation(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	registerWorker(mockStub, workerB)
+	registerWorker(mockStub, workerC)
+	registerItem(t, *mockStub, "traintuple")
+	mockStub.MockTransactionStart(mockTxID)
+	db := NewLedgerDB(mockStub)
+
+	// the in-models are run by workerA, workerB and workerC
+	fromB := inputAggregatetuple{Key: RandomUUID(), Worker: workerB}
+	fromB.fillDefaults()
+	_, err := createAggregatetuple(db, assetToArgs(fromB))
+	require.NoError(t, err)
+	fromC := inputAggregatetuple{Key: RandomUUID(), Worker: workerC}
+	fromC.fillDefaults()
+	_, err = createAggregatetuple(db, assetToArgs(fromC))
+	require.NoError(t, err)
+	aggregate := inputAggregatetuple{Key: RandomUUID(), InModels: []string{traintupleKey, fromB.Key, fromC.Key}}
+	aggregate.fillDefaults()
+	_, err = createAggregatetuple(db, assetToArgs(aggregate))
+	require.NoError(t, err)
+
+	as := func(node string, f func() error) error {
+		mockStub.Creator = node
+		defer func() { mockStub.Creator = workerA }()
+		return f()
+	}
+	key := RandomUUID()
+	inp := inputSecureAggregation{Key: key, AggregatetupleKey: aggregate.Key}
+	err = as(workerB, func() error {
+		_, err := createSecureAggregation(db, assetToArgs(inp))
+		return err
+	})
+	assert.Error(t, err, "only the aggregatetuple creator can start a secure aggregation")
+	out, err := createSecureAggregation(db, assetToArgs(inp))
+	require.NoError(t, err)
+	assert.Equal(t, 2, out.Threshold)
+	assert.ElementsMatch(t, []string{workerA, workerB, workerC}, out.Participants)
+	_, err = createSecureAggregation(db, assetToArgs(inputSecureAggregation{Key: RandomUUID(), AggregatetupleKey: aggregate.Key}))
+	assert.Error(t, err, "an aggregatetuple runs a single secure aggregation")
+
+	// all the participants advertise their key
+	for _, node := range []string{workerA, workerB, workerC} {
+		err = as(node, func() error {
+			out, err = advertiseSecureAggregationKey(db, assetToArgs(inputSecureAggregationKey{Key: key, PublicKey: "pk-" + node}))
+			return err
+		})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, SecureAggregationPhaseShareKeys, out.Phase)
+
+	// workerC drops out while the keys are shared
+	_, err = shareSecureAggregationKeys(db, assetToArgs(inputSecureAggregationShares{Key: key, Shares: map[string]string{workerB: "s"}}))
+	assert.Error(t, err, "shares must be addressed to all the other participants")
+	_, err = shareSecureAggregationKeys(db, assetToArgs(inputSecureAggregationShares{Key: key, Shares: map[string]string{workerB: "s", workerC: "s"}}))
+	require.NoError(t, err)
+	err = as(workerB, func() error {
+		_, err := shareSecureAggregationKeys(db, assetToArgs(inputSecureAggregationShares{Key: key, Shares: map[string]string{workerA: "s", workerC: "s"}}))
+		return err
+	})
+	require.NoError(t, err)
+	err = as(workerB, func() error {
+		_, err := advanceSecureAggregation(db, keyToArgs(key))
+		return err
+	})
+	assert.Error(t, err, "only the aggregator can close a phase")
+	out, err = advanceSecureAggregation(db, keyToArgs(key))
+	require.NoError(t, err)
+	assert.Equal(t, SecureAggregationPhaseMaskedInput, out.Phase)
+	assert.ElementsMatch(t, []string{workerA, workerB}, out.Active)
+
+	// the aggregatetuple cannot start before the protocol is done
+	_, err = logStartAggregate(db, keyToArgs(aggregate.Key))
+	assert.Error(t, err)
+
+	// the remaining participants commit their masks once their tuple is done
+	_, err = commitSecureAggregationMask(db, assetToArgs(inputSecureAggregationCommitment{Key: key, TupleKey: traintupleKey, Commitment: "c"}))
+	assert.Error(t, err, "the tuple must be done")
+	_, err = logStartTrain(db, keyToArgs(traintupleKey))
+	require.NoError(t, err)
+	success := inputLogSuccessTrain{}
+	success.fillDefaults()
+	_, err = logSuccessTrain(db, assetToArgs(success))
+	require.NoError(t, err)
+	_, err = commitSecureAggregationMask(db, assetToArgs(inputSecureAggregationCommitment{Key: key, TupleKey: fromB.Key, Commitment: "c"}))
+	assert.Error(t, err, "a participant commits the mask of its own tuple")
+	_, err = commitSecureAggregationMask(db, assetToArgs(inputSecureAggregationCommitment{Key: key, TupleKey: traintupleKey, Commitment: "c"}))
+	require.NoError(t, err)
+	err = as(workerC, func() error {
+		_, err := commitSecureAggregationMask(db, assetToArgs(inputSecureAggregationCommitment{Key: key, TupleKey: fromC.Key, Commitment: "c"}))
+		return err
+	})
+	assert.Error(t, err, "dropped participants cannot come back")
+	runAggregatetuple := func(key string) error {
+		if _, err := logStartAggregate(db, keyToArgs(key)); err != nil {
+			return err
+		}
+		success := inputLogSuccessTrain{}
+		success.Key = key
+		success.OutModel.Key = RandomUUID()
+		success.fillDefaults()
+		_, err := logSuccessAggregate(db, assetToArgs(success))
+		return err
+	}
+	err = as(workerC, func() error { return runAggregatetuple(fromC.Key) })
+	require.NoError(t, err)
+	err = as(workerB, func() error {
+		if err := runAggregatetuple(fromB.Key); err != nil {
+			return err
+		}
+		out, err = commitSecureAggregationMask(db, assetToArgs(inputSecureAggregationCommitment{Key: key, TupleKey: fromB.Key, Commitment: "c"}))
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, SecureAggregationPhaseUnmasking, out.Phase)
+
+	// the threshold of participants reveal their shares
+	_, err = revealSecureAggregationShares(db, assetToArgs(inputSecureAggregationShares{Key: key, Shares: map[string]string{workerB: "r", workerC: "r"}}))
+	assert.Error(t, err, "workerC did not share its keys")
+	out, err = revealSecureAggregationShares(db, assetToArgs(inputSecureAggregationShares{Key: key, Shares: map[string]string{workerB: "r"}}))
+	require.NoError(t, err)
+	assert.Equal(t, SecureAggregationPhaseUnmasking, out.Phase)
+	err = as(workerB, func() error {
+		out, err = revealSecureAggregationShares(db, assetToArgs(inputSecureAggregationShares{Key: key, Shares: map[string]string{workerA: "r"}}))
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, SecureAggregationPhaseDone, out.Phase)
+
+	_, err = logStartAggregate(db, keyToArgs(aggregate.Key))
+	assert.NoError(t, err)
+	tuple, err := queryAggregatetuple(db, keyToArgs(aggregate.Key))
+	require.NoError(t, err)
+	assert.Equal(t, key, tuple.SecureAggregationKey)
+}
+
+func TestSecureAggregationAborted(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	registerWorker(mockStub, workerB)
+	registerItem(t, *mockStub, "traintuple")
+	mockStub.MockTransactionStart(mockTxID)
+	db := NewLedgerDB(mockStub)
+
+	fromB := inputAggregatetuple{Key: RandomUUID(), Worker: workerB}
+	fromB.fillDefaults()
+	_, err := createAggregatetuple(db, assetToArgs(fromB))
+	require.NoError(t, err)
+	aggregate := inputAggregatetuple{Key: RandomUUID(), InModels: []string{traintupleKey, fromB.Key}}
+	aggregate.fillDefaults()
+	_, err = createAggregatetuple(db, assetToArgs(aggregate))
+	require.NoError(t, err)
+
+	key := RandomUUID()
+	_, err = createSecureAggregation(db, assetToArgs(inputSecureAggregation{Key: key, AggregatetupleKey: aggregate.Key, Threshold: 3}))
+	assert.Error(t, err, "the threshold cannot exceed the number of participants")
+	_, err = createSecureAggregation(db, assetToArgs(inputSecureAggregation{Key: key, AggregatetupleKey: aggregate.Key}))
+	require.NoError(t, err)
+	_, err = advertiseSecureAggregationKey(db, assetToArgs(inputSecureAggregationKey{Key: key, PublicKey: "pk"}))
+	require.NoError(t, err)
+
+	// workerB never advertises its key
+	out, err := advanceSecureAggregation(db, keyToArgs(key))
+	require.NoError(t, err)
+	assert.Equal(t, SecureAggregationPhaseAborted, out.Phase)
+	_, err = advanceSecureAggregation(db, keyToArgs(key))
+	assert.Error(t, err)
+
+	// the aggregatetuple fails rather than aggregating in the clear
+	tuple, err := db.GetAggregatetuple(aggregate.Key)
+	require.NoError(t, err)
+	assert.Equal(t, StatusFailed, tuple.Status)
+	_, err = logStartAggregate(db, keyToArgs(aggregate.Key))
+	assert.Error(t, err)
+}
@@ -58,6 +58,10 @@ func (testtuple *Testtuple) SetFromInput(db *LedgerDB, inp inputTesttuple) error
 	testtuple.Key = inp.Key
 	testtuple.Creator = creator
 	testtuple.Tag = inp.Tag
+	err = checkMetadata(db, inp.Metadata)
+	if err != nil {
+		return err
+	}
 	testtuple.Metadata = inp.Metadata
 	testtuple.AssetType = TesttupleType
 
@@ -76,12 +80,13 @@ func (testtuple *Testtuple) SetFromInput(db *LedgerDB, inp inputTesttuple) error
 
 	var dataManagerKey string
 	var dataSampleKeys []string
+	var testOnly bool
 	switch {
 	case len(inp.DataManagerKey) > 0 && len(inp.DataSampleKeys) > 0:
 		// non-certified testtuple
 		// test dataset are specified by the user
 		dataSampleKeys = inp.DataSampleKeys
-		_, _, err = checkSameDataManager(db, inp.DataManagerKey, dataSampleKeys)
+		testOnly, _, err = checkSameDataManager(db, inp.DataManagerKey, dataSampleKeys)
 		if err != nil {
 			return err
 		}
@@ -92,6 +97,10 @@ func (testtuple *Testtuple) SetFromInput(db *LedgerDB, inp inputTesttuple) error
 	case objective.TestDataset != nil:
 		dataSampleKeys = objectiveDataSampleKeys
 		dataManagerKey = objectiveDataManagerKey
+		testOnly, _, err = checkSameDataManager(db, dataManagerKey, dataSampleKeys)
+		if err != nil {
+			return err
+		}
 		testtuple.Certified = true
 	default:
 		return errors.BadRequest("can not create a certified testtuple, no data associated with objective %s", testtuple.ObjectiveKey)
@@ -107,6 +116,27 @@ func (testtuple *Testtuple) SetFromInput(db *LedgerDB, inp inputTesttuple) error
 		DataSampleKeys: dataSampleKeys,
 		OpenerChecksum: dataManager.Opener.Checksum,
 	}
+	if err = checkWorkerQuotas(db, testtuple.Dataset.Worker, creator); err != nil {
+		return err
+	}
+	if err = dataManager.UsagePolicy.checkTestDataSamples(dataManager.Key, testOnly); err != nil {
+		return err
+	}
+	if dataManager.UsagePolicy != nil {
+		algo, err := db.GetAlgoOfAnyType(testtuple.AlgoKey)
+		if err != nil {
+			return err
+		}
+		if err = dataManager.UsagePolicy.checkAlgo(dataManager.Key, algo.Key, algo.Owner); err != nil {
+			return err
+		}
+	}
+	if testtuple.Certified {
+		if err = objective.checkChallengeSubmission(db, creator); err != nil {
+			return err
+		}
+		testtuple.TestDatasetVersion = objective.GetTestDatasetVersion()
+	}
 	return nil
 }
 
@@ -168,7 +198,11 @@ func (testtuple *Testtuple) SetFromTraintuple(db *LedgerDB, traintupleKey string
 		return errors.BadRequest("key %s is not a valid traintuple", traintupleKey)
 	}
 
-	if !permissions.CanProcess(tupleCreator, creator) {
+	canProcess, err := permissions.CanProcess(db, tupleCreator, creator)
+	if err != nil {
+		return err
+	}
+	if !canProcess {
 		return errors.Forbidden("not authorized to process traintuple %s", traintupleKey)
 	}
 	switch status {
@@ -216,15 +250,24 @@ func (testtuple *Testtuple) Save(db *LedgerDB, testtupleKey string) error {
 	if err = db.CreateIndex("testtuple~objective~certified~key", []string{"testtuple", testtuple.ObjectiveKey, strconv.FormatBool(testtuple.Certified), testtupleKey}); err != nil {
 		return err
 	}
+	if err = db.CreateIndex("testtuple~objective~certified~creator~key", []string{"testtuple", testtuple.ObjectiveKey, strconv.FormatBool(testtuple.Certified), testtuple.Creator, testtupleKey}); err != nil {
+		return err
+	}
 	if err = db.CreateIndex("testtuple~algo~key", []string{"testtuple", testtuple.AlgoKey, testtupleKey}); err != nil {
 		return err
 	}
 	if err = db.CreateIndex("testtuple~worker~status~key", []string{"testtuple", testtuple.Dataset.Worker, testtuple.Status, testtupleKey}); err != nil {
 		return err
 	}
+	if err = countTupleCreation(db, testtuple.Dataset.Worker, testtuple.Creator, testtuple.Status); err != nil {
+		return err
+	}
 	if err = db.CreateIndex("testtuple~traintuple~certified~key", []string{"testtuple", testtuple.TraintupleKey, strconv.FormatBool(testtuple.Certified), testtupleKey}); err != nil {
 		return err
 	}
+	if err = createDataSampleIndexes(db, testtuple.Dataset.DataSampleKeys, testtupleKey); err != nil {
+		return err
+	}
 	if testtuple.Tag != "" {
 		err = db.CreateIndex("testtuple~tag~key", []string{"traintuple", testtuple.Tag, testtupleKey})
 		if err != nil {
@@ -234,6 +277,45 @@ func (testtuple *Testtuple) Save(db *LedgerDB, testtupleKey string) error {
 	return nil
 }
 
+// GetTestDatasetVersion returns the version of the objective test dataset a certified
+// testtuple runs on. Testtuples certified before test datasets were versioned ran on the first one.
+func (testtuple *Testtuple) GetTestDatasetVersion() int {
+	if testtuple.TestDatasetVersion == 0 && testtuple.Certified {
+		return 1
+	}
+	return testtuple.TestDatasetVersion
+}
+
+// setPerfs checks the metrics reported for a testtuple against the ones declared
+// by its objective and stores them. The perf defaults to the value of the main
+// metric, and must match it when both are reported.
+func (testtuple *Testtuple) setPerfs(db *LedgerDB, perf *float32, perfs map[string]float32) error {
+	objective, err := db.GetObjective(testtuple.ObjectiveKey)
+	if err != nil {
+		return err
+	}
+	metricNames := objective.GetMetricNames()
+	for name := range perfs {
+		if !stringInSlice(name, metricNames) {
+			return errors.BadRequest("metric %s is not declared by objective %s", name, testtuple.ObjectiveKey)
+		}
+	}
+	if len(metricNames) > 0 {
+		if mainPerf, ok := perfs[metricNames[0]]; ok {
+			if perf != nil && *perf != mainPerf {
+				return errors.BadRequest("perf %v does not match the value %v of the main metric %s", *perf, mainPerf, metricNames[0])
+			}
+			perf = &mainPerf
+		}
+	}
+	testtuple.Dataset.Perf = 0
+	if perf != nil {
+		testtuple.Dataset.Perf = *perf
+	}
+	testtuple.Dataset.Perfs = perfs
+	return nil
+}
+
 // -------------------------------------
 // Smart contracts related to testuples
 // -------------------------------------
@@ -320,8 +402,16 @@ func logSuccessTest(db *LedgerDB, args []string) (o outputTesttuple, err error)
 		return
 	}
 
-	testtuple.Dataset.Perf = inp.Perf
-	testtuple.Log += inp.Log
+	if err = testtuple.setPerfs(db, inp.Perf, inp.Perfs); err != nil {
+		return
+	}
+	testtuple.Log, err = appendLog(db, testtuple.Log, inp.Log)
+	if err != nil {
+		return
+	}
+	if testtuple.DoneTimestamp, err = db.GetTxTimestamp(); err != nil {
+		return
+	}
 
 	if err = validateTupleOwner(db, testtuple.Dataset.Worker); err != nil {
 		return
@@ -329,7 +419,13 @@ func logSuccessTest(db *LedgerDB, args []string) (o outputTesttuple, err error)
 	if err = testtuple.commitStatusUpdate(db, inp.Key, status); err != nil {
 		return
 	}
-	err = o.Fill(db, testtuple)
+	if testtuple.Certified {
+		if err = createLeaderboardIndexes(db, testtuple, inp.Key); err != nil {
+			return
+		}
+	}
+	// the response is recorded in the transaction, readable by all the nodes
+	err = o.FillForChannel(db, testtuple)
 	return
 }
 
@@ -348,7 +444,10 @@ func logFailTest(db *LedgerDB, args []string) (o outputTesttuple, err error) {
 		return
 	}
 
-	testtuple.Log += inp.Log
+	testtuple.Log, err = appendLog(db, testtuple.Log, inp.Log)
+	if err != nil {
+		return
+	}
 
 	if err = validateTupleOwner(db, testtuple.Dataset.Worker); err != nil {
 		return
@@ -396,7 +495,7 @@ func queryTesttuples(db *LedgerDB, args []string) (outTesttuples []outputTesttup
 		}
 	}
 
-	elementsKeys, bookmark, err := db.GetIndexKeysWithPagination("testtuple~traintuple~certified~key", []string{"testtuple"}, OutputPageSize, inp.Bookmark)
+	elementsKeys, bookmark, err := db.GetIndexKeysWithPagination("testtuple~traintuple~certified~key", []string{"testtuple"}, db.config.PageSize, inp.Bookmark)
 
 	if err != nil {
 		return
@@ -452,15 +551,21 @@ func (testtuple *Testtuple) commitStatusUpdate(db *LedgerDB, testtupleKey string
 		return nil
 	}
 
-	if err := testtuple.validateNewStatus(db, newStatus); err != nil {
-		return errors.Internal("update testtuple %s failed: %s", testtupleKey, err.Error())
-	}
-
 	// do not update if previous status is already Done, Failed, Todo, Doing
 	if StatusAborted == newStatus && testtuple.Status != StatusWaiting {
 		return nil
 	}
 
+	return testtuple.saveStatusUpdate(db, testtupleKey, newStatus)
+}
+
+// saveStatusUpdate updates the testtuple status in the ledger, without
+// restricting the statuses it can be aborted from
+func (testtuple *Testtuple) saveStatusUpdate(db *LedgerDB, testtupleKey string, newStatus string) error {
+	if err := testtuple.validateNewStatus(db, newStatus); err != nil {
+		return errors.Internal("update testtuple %s failed: %s", testtupleKey, err.Error())
+	}
+
 	oldStatus := testtuple.Status
 	testtuple.Status = newStatus
 
@@ -475,6 +580,9 @@ func (testtuple *Testtuple) commitStatusUpdate(db *LedgerDB, testtupleKey string
 	if err := db.UpdateIndex(indexName, oldAttributes, newAttributes); err != nil {
 		return err
 	}
+	if err := countTupleStatusUpdate(db, testtuple.Dataset.Worker, testtuple.Creator, oldStatus, newStatus); err != nil {
+		return err
+	}
 	if err := UpdateComputePlanState(db, testtuple.ComputePlanKey, newStatus, testtupleKey, testtuple.Dataset.Worker); err != nil {
 		return err
 	}
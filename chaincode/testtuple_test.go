@@ -21,6 +21,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type TesttupleResponse struct {
@@ -251,6 +252,7 @@ func TestTesttupleOnCompositeTraintuple(t *testing.T) {
 			assert.Equal(t, compositeTraintupleKey, testTuple.TraintupleKey)
 
 			// Create a new testtuple *after* the traintuple has been set to failed/succeeded
+			assert.NoError(t, db.Flush())
 			inp.Key = RandomUUID()
 			inp.DataManagerKey = dataManagerKey
 			inp.DataSampleKeys = []string{trainDataSampleKey1}
@@ -275,3 +277,78 @@ func TestTesttupleOnCompositeTraintuple(t *testing.T) {
 		})
 	}
 }
+
+func TestLogSuccessTestPerfs(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	registerItem(t, *mockStub, "traintuple")
+	mockStub.MockTransactionStart("42")
+	db := NewLedgerDB(mockStub)
+
+	// declare several metrics on the objective
+	objective, err := db.GetObjective(objectiveKey)
+	require.NoError(t, err)
+	objective.MetricNames = []string{"auc", "accuracy"}
+	err = db.Put(objectiveKey, objective)
+	require.NoError(t, err)
+
+	traintupleToDone(t, db, traintupleKey)
+	testtupleKeys := []string{}
+	for i := 0; i < 3; i++ {
+		inpTesttuple := inputTesttuple{Key: RandomUUID(), TraintupleKey: traintupleKey, ObjectiveKey: objectiveKey}
+		_, err = createTesttuple(db, assetToArgs(inpTesttuple))
+		require.NoError(t, err)
+		_, err = logStartTest(db, assetToArgs(inputKey{Key: inpTesttuple.Key}))
+		require.NoError(t, err)
+		testtupleKeys = append(testtupleKeys, inpTesttuple.Key)
+	}
+
+	success := inputLogSuccessTest{}
+	success.Key = testtupleKeys[0]
+	success.Perfs = map[string]float32{"auc": 0.8, "recall": 0.1}
+	_, err = logSuccessTest(db, assetToArgs(success))
+	assert.Error(t, err, "metrics which are not declared by the objective should be rejected")
+
+	perf := float32(0.5)
+	success.Perf = &perf
+	success.Perfs = map[string]float32{"auc": 0.8, "accuracy": 0.6}
+	_, err = logSuccessTest(db, assetToArgs(success))
+	assert.Error(t, err, "the perf should match the main metric")
+
+	success.Perf = nil
+	out, err := logSuccessTest(db, assetToArgs(success))
+	require.NoError(t, err)
+	assert.EqualValues(t, 0.8, out.Dataset.Perf, "perf should default to the main metric")
+	assert.Equal(t, success.Perfs, out.Dataset.Perfs)
+
+	success.Key = testtupleKeys[1]
+	perf = 0
+	success.Perf = &perf
+	success.Perfs = map[string]float32{"auc": 0, "accuracy": 0.9}
+	out, err = logSuccessTest(db, assetToArgs(success))
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, out.Dataset.Perf, "a perf of 0 is a valid score")
+
+	// testtuples without the metric are not ranked by it
+	perf = 0.95
+	success = inputLogSuccessTest{Perf: &perf}
+	success.Key = testtupleKeys[2]
+	_, err = logSuccessTest(db, assetToArgs(success))
+	require.NoError(t, err)
+
+	inpLeaderboard := inputLeaderboard{ObjectiveKey: objectiveKey, Metric: "accuracy"}
+	leaderboard, err := queryObjectiveLeaderboard(db, assetToArgs(inpLeaderboard))
+	require.NoError(t, err)
+	require.Len(t, leaderboard.Testtuples, 2)
+	assert.Equal(t, testtupleKeys[1], leaderboard.Testtuples[0].Key)
+	assert.Equal(t, testtupleKeys[0], leaderboard.Testtuples[1].Key)
+
+	inpLeaderboard.RankingMode = RankingBestPerAlgo
+	_, err = queryObjectiveLeaderboard(db, assetToArgs(inpLeaderboard))
+	assert.Error(t, err, "ranking groups are only indexed for the main metric")
+	inpLeaderboard.RankingMode = ""
+
+	inpLeaderboard.Metric = "recall"
+	_, err = queryObjectiveLeaderboard(db, assetToArgs(inpLeaderboard))
+	assert.Error(t, err)
+}
@@ -43,15 +43,26 @@ func (traintuple *Traintuple) SetFromInput(db *LedgerDB, inp inputTraintuple) er
 	traintuple.AssetType = TraintupleType
 	traintuple.Creator = creator
 	traintuple.ComputePlanKey = inp.ComputePlanKey
+	err = checkMetadata(db, inp.Metadata)
+	if err != nil {
+		return err
+	}
 	traintuple.Metadata = inp.Metadata
 	traintuple.Tag = inp.Tag
 	algo, err := db.GetAlgo(inp.AlgoKey)
 	if err != nil {
 		return errors.BadRequest(err, "could not retrieve algo with key %s", inp.AlgoKey)
 	}
-	if !algo.Permissions.CanProcess(algo.Owner, creator) {
+	canProcess, err := algo.Permissions.CanProcess(db, algo.Owner, creator)
+	if err != nil {
+		return err
+	}
+	if !canProcess {
 		return errors.Forbidden("not authorized to process algo %s", inp.AlgoKey)
 	}
+	if err := algo.checkUsable(); err != nil {
+		return err
+	}
 	traintuple.AlgoKey = inp.AlgoKey
 
 	// check if DataSampleKeys are from the same dataManager and if they are not test only dataSample
@@ -67,11 +78,26 @@ func (traintuple *Traintuple) SetFromInput(db *LedgerDB, inp inputTraintuple) er
 	if err != nil {
 		return errors.BadRequest(err, "could not retrieve dataManager with key \"%s\"", inp.DataManagerKey)
 	}
-	if !dataManager.Permissions.CanProcess(dataManager.Owner, creator) {
+	canProcess, err = dataManager.Permissions.CanProcess(db, dataManager.Owner, creator)
+	if err != nil {
+		return err
+	}
+	if !canProcess {
 		return errors.Forbidden("not authorized to process dataManager %s", inp.DataManagerKey)
 	}
 
-	traintuple.Permissions = MergePermissions(dataManager.Permissions, algo.Permissions)
+	if err = dataManager.UsagePolicy.checkAlgo(dataManager.Key, algo.Key, algo.Owner); err != nil {
+		return err
+	}
+	if err = dataManager.UsagePolicy.checkTrainDataSamples(dataManager.Key, inp.DataSampleKeys); err != nil {
+		return err
+	}
+
+	traintuple.Permissions, err = MergePermissions(db, dataManager.Permissions, algo.Permissions)
+	if err != nil {
+		return err
+	}
+	traintuple.Permissions = dataManager.UsagePolicy.restrictModelPermissions(traintuple.Permissions, []string{dataManager.Owner, creator})
 
 	// fill traintuple.Dataset from dataManager and dataSample
 	traintuple.Dataset = &Dataset{
@@ -79,7 +105,10 @@ func (traintuple *Traintuple) SetFromInput(db *LedgerDB, inp inputTraintuple) er
 		DataSampleKeys: inp.DataSampleKeys,
 	}
 	traintuple.Dataset.Worker, err = getDataManagerOwner(db, traintuple.Dataset.DataManagerKey)
-	return err
+	if err != nil {
+		return err
+	}
+	return checkWorkerQuotas(db, traintuple.Dataset.Worker, creator)
 }
 
 // SetFromParents set the status of the traintuple depending on its "parents",
@@ -168,11 +197,17 @@ func (traintuple *Traintuple) Save(db *LedgerDB, traintupleKey string) error {
 	if err := db.CreateIndex("traintuple~worker~status~key", []string{"traintuple", traintuple.Dataset.Worker, traintuple.Status, traintupleKey}); err != nil {
 		return err
 	}
+	if err := countTupleCreation(db, traintuple.Dataset.Worker, traintuple.Creator, traintuple.Status); err != nil {
+		return err
+	}
 	for _, inModelKey := range traintuple.InModelKeys {
 		if err := db.CreateIndex("tuple~inModel~key", []string{"tuple", inModelKey, traintupleKey}); err != nil {
 			return err
 		}
 	}
+	if err := createDataSampleIndexes(db, traintuple.Dataset.DataSampleKeys, traintupleKey); err != nil {
+		return err
+	}
 	if traintuple.ComputePlanKey != "" {
 		if err := db.CreateIndex("computePlan~computeplankey~worker~rank~key", []string{"computePlan", traintuple.ComputePlanKey, traintuple.Dataset.Worker, strconv.Itoa(traintuple.Rank), traintupleKey}); err != nil {
 			return err
@@ -293,7 +328,10 @@ func logSuccessTrain(db *LedgerDB, args []string) (o outputTraintuple, err error
 		Key:            inp.OutModel.Key,
 		Checksum:       inp.OutModel.Checksum,
 		StorageAddress: inp.OutModel.StorageAddress}
-	traintuple.Log += inp.Log
+	traintuple.Log, err = appendLog(db, traintuple.Log, inp.Log)
+	if err != nil {
+		return
+	}
 
 	err = createModelIndex(db, inp.OutModel.Key, traintupleKey)
 	if err != nil {
@@ -343,7 +381,10 @@ func logFailTrain(db *LedgerDB, args []string) (o outputTraintuple, err error) {
 		return
 	}
 
-	traintuple.Log += inp.Log
+	traintuple.Log, err = appendLog(db, traintuple.Log, inp.Log)
+	if err != nil {
+		return
+	}
 
 	if err = validateTupleOwner(db, traintuple.Dataset.Worker); err != nil {
 		return
@@ -406,7 +447,7 @@ func queryTraintuples(db *LedgerDB, args []string) (outTraintuples []outputTrain
 		}
 	}
 
-	elementsKeys, bookmark, err := db.GetIndexKeysWithPagination("traintuple~algo~key", []string{"traintuple"}, OutputPageSize, inp.Bookmark)
+	elementsKeys, bookmark, err := db.GetIndexKeysWithPagination("traintuple~algo~key", []string{"traintuple"}, db.config.PageSize, inp.Bookmark)
 
 	if err != nil {
 		return
@@ -533,8 +574,8 @@ func UpdateTraintupleChild(db *LedgerDB, parentTraintupleKey string, childTraint
 
 	// get traintuple new status
 	var newStatus string
-	if traintupleStatus == StatusFailed {
-		newStatus = StatusFailed
+	if stringInSlice(traintupleStatus, []string{StatusFailed, StatusAborted}) {
+		newStatus = traintupleStatus
 	} else if traintupleStatus == StatusDone {
 		ready, _err := childTraintuple.isReady(db, parentTraintupleKey)
 		if _err != nil {
@@ -595,6 +636,12 @@ func (traintuple *Traintuple) commitStatusUpdate(db *LedgerDB, traintupleKey str
 		return nil
 	}
 
+	return traintuple.saveStatusUpdate(db, traintupleKey, newStatus)
+}
+
+// saveStatusUpdate updates the traintuple status in the ledger, without
+// restricting the statuses it can be aborted from
+func (traintuple *Traintuple) saveStatusUpdate(db *LedgerDB, traintupleKey string, newStatus string) error {
 	if err := traintuple.validateNewStatus(db, newStatus); err != nil {
 		return errors.Internal("update traintuple %s failed: %s", traintupleKey, err.Error())
 	}
@@ -612,6 +659,9 @@ func (traintuple *Traintuple) commitStatusUpdate(db *LedgerDB, traintupleKey str
 	if err := db.UpdateIndex(indexName, oldAttributes, newAttributes); err != nil {
 		return err
 	}
+	if err := countTupleStatusUpdate(db, traintuple.Dataset.Worker, traintuple.Creator, oldStatus, newStatus); err != nil {
+		return err
+	}
 	if err := UpdateComputePlanState(db, traintuple.ComputePlanKey, newStatus, traintupleKey, traintuple.Dataset.Worker); err != nil {
 		return err
 	}
@@ -625,6 +675,8 @@ func UpdateTesttupleChildren(db *LedgerDB, traintupleKey string, traintupleStatu
 	switch {
 	case traintupleStatus == StatusFailed:
 		newStatus = StatusFailed
+	case traintupleStatus == StatusAborted:
+		newStatus = StatusAborted
 	case traintupleStatus == StatusDone:
 		newStatus = StatusTodo
 	default:
@@ -41,15 +41,26 @@ func (traintuple *CompositeTraintuple) SetFromInput(db *LedgerDB, inp inputCompo
 	traintuple.AssetType = CompositeTraintupleType
 	traintuple.Creator = creator
 	traintuple.ComputePlanKey = inp.ComputePlanKey
+	err = checkMetadata(db, inp.Metadata)
+	if err != nil {
+		return err
+	}
 	traintuple.Metadata = inp.Metadata
 	traintuple.Tag = inp.Tag
 	algo, err := db.GetCompositeAlgo(inp.AlgoKey)
 	if err != nil {
 		return errors.BadRequest(err, "could not retrieve Composite algo with key %s", inp.AlgoKey)
 	}
-	if !algo.Permissions.CanProcess(algo.Owner, creator) {
+	canProcess, err := algo.Permissions.CanProcess(db, algo.Owner, creator)
+	if err != nil {
+		return err
+	}
+	if !canProcess {
 		return errors.Forbidden("not authorized to process algo %s", inp.AlgoKey)
 	}
+	if err := algo.checkUsable(); err != nil {
+		return err
+	}
 	traintuple.AlgoKey = inp.AlgoKey
 
 	// check if DataSampleKeys are from the same dataManager and if they are not test only dataSample
@@ -65,9 +76,19 @@ func (traintuple *CompositeTraintuple) SetFromInput(db *LedgerDB, inp inputCompo
 	if err != nil {
 		return errors.BadRequest(err, "could not retrieve dataManager with key %s", inp.DataManagerKey)
 	}
-	if !dataManager.Permissions.CanProcess(dataManager.Owner, creator) {
+	canProcess, err = dataManager.Permissions.CanProcess(db, dataManager.Owner, creator)
+	if err != nil {
+		return err
+	}
+	if !canProcess {
 		return errors.Forbidden("not authorized to process dataManager %s", inp.DataManagerKey)
 	}
+	if err = dataManager.UsagePolicy.checkAlgo(dataManager.Key, algo.Key, algo.Owner); err != nil {
+		return err
+	}
+	if err = dataManager.UsagePolicy.checkTrainDataSamples(dataManager.Key, inp.DataSampleKeys); err != nil {
+		return err
+	}
 
 	// fill traintuple.Dataset from dataManager and dataSample
 	traintuple.Dataset = &Dataset{
@@ -75,6 +96,12 @@ func (traintuple *CompositeTraintuple) SetFromInput(db *LedgerDB, inp inputCompo
 		DataSampleKeys: inp.DataSampleKeys,
 	}
 	traintuple.Dataset.Worker, err = getDataManagerOwner(db, traintuple.Dataset.DataManagerKey)
+	if err != nil {
+		return err
+	}
+	if err = checkWorkerQuotas(db, traintuple.Dataset.Worker, creator); err != nil {
+		return err
+	}
 
 	// permissions (head): worker only where the data belong
 	workerOnly := Permission{
@@ -87,7 +114,7 @@ func (traintuple *CompositeTraintuple) SetFromInput(db *LedgerDB, inp inputCompo
 	if err != nil {
 		return err
 	}
-	traintuple.OutTrunkModel.Permissions = permissions
+	traintuple.OutTrunkModel.Permissions = dataManager.UsagePolicy.restrictModelPermissions(permissions, []string{dataManager.Owner, creator})
 
 	return err
 }
@@ -209,6 +236,9 @@ func (traintuple *CompositeTraintuple) Save(db *LedgerDB, traintupleKey string)
 	if err := db.CreateIndex("compositeTraintuple~worker~status~key", []string{"compositeTraintuple", traintuple.Dataset.Worker, traintuple.Status, traintupleKey}); err != nil {
 		return err
 	}
+	if err := countTupleCreation(db, traintuple.Dataset.Worker, traintuple.Creator, traintuple.Status); err != nil {
+		return err
+	}
 	// TODO: Do we create an index for head/trunk inModel or do we concider that
 	// they are classic inModels ?
 	if err := db.CreateIndex("tuple~inModel~key", []string{"tuple", traintuple.InHeadModel, traintupleKey}); err != nil {
@@ -217,6 +247,9 @@ func (traintuple *CompositeTraintuple) Save(db *LedgerDB, traintupleKey string)
 	if err := db.CreateIndex("tuple~inModel~key", []string{"tuple", traintuple.InTrunkModel, traintupleKey}); err != nil {
 		return err
 	}
+	if err := createDataSampleIndexes(db, traintuple.Dataset.DataSampleKeys, traintupleKey); err != nil {
+		return err
+	}
 	if traintuple.ComputePlanKey != "" {
 		if err := db.CreateIndex("computePlan~computeplankey~worker~rank~key", []string{"computePlan", traintuple.ComputePlanKey, traintuple.Dataset.Worker, strconv.Itoa(traintuple.Rank), traintupleKey}); err != nil {
 			return err
@@ -341,7 +374,10 @@ func logSuccessCompositeTrain(db *LedgerDB, args []string) (o outputCompositeTra
 		Key:            inp.OutTrunkModel.Key,
 		Checksum:       inp.OutTrunkModel.Checksum,
 		StorageAddress: inp.OutTrunkModel.StorageAddress}
-	compositeTraintuple.Log += inp.Log
+	compositeTraintuple.Log, err = appendLog(db, compositeTraintuple.Log, inp.Log)
+	if err != nil {
+		return
+	}
 
 	err = createModelIndex(db, inp.OutHeadModel.Key, compositeTraintupleKey)
 	if err != nil {
@@ -398,7 +434,10 @@ func logFailCompositeTrain(db *LedgerDB, args []string) (o outputCompositeTraint
 		return
 	}
 
-	compositeTraintuple.Log += inp.Log
+	compositeTraintuple.Log, err = appendLog(db, compositeTraintuple.Log, inp.Log)
+	if err != nil {
+		return
+	}
 
 	if err = validateTupleOwner(db, compositeTraintuple.Dataset.Worker); err != nil {
 		return
@@ -461,7 +500,7 @@ func queryCompositeTraintuples(db *LedgerDB, args []string) (outTraintuples []ou
 		}
 	}
 
-	elementsKeys, bookmark, err := db.GetIndexKeysWithPagination("compositeTraintuple~algo~key", []string{"compositeTraintuple"}, OutputPageSize, inp.Bookmark)
+	elementsKeys, bookmark, err := db.GetIndexKeysWithPagination("compositeTraintuple~algo~key", []string{"compositeTraintuple"}, db.config.PageSize, inp.Bookmark)
 
 	if err != nil {
 		return
@@ -493,8 +532,8 @@ func UpdateCompositeTraintupleChild(db *LedgerDB, parentTraintupleKey string, ch
 
 	// get traintuple new status
 	var newStatus string
-	if traintupleStatus == StatusFailed {
-		newStatus = StatusFailed
+	if stringInSlice(traintupleStatus, []string{StatusFailed, StatusAborted}) {
+		newStatus = traintupleStatus
 	} else if traintupleStatus == StatusDone {
 		ready, _err := childTraintuple.isReady(db, parentTraintupleKey)
 		if _err != nil {
@@ -568,6 +607,12 @@ func (traintuple *CompositeTraintuple) commitStatusUpdate(db *LedgerDB, traintup
 		return nil
 	}
 
+	return traintuple.saveStatusUpdate(db, traintupleKey, newStatus)
+}
+
+// saveStatusUpdate updates the traintuple status in the ledger, without
+// restricting the statuses it can be aborted from
+func (traintuple *CompositeTraintuple) saveStatusUpdate(db *LedgerDB, traintupleKey string, newStatus string) error {
 	if err := traintuple.validateNewStatus(db, newStatus); err != nil {
 		return errors.Internal("update traintuple %s failed: %s", traintupleKey, err.Error())
 	}
@@ -585,6 +630,9 @@ func (traintuple *CompositeTraintuple) commitStatusUpdate(db *LedgerDB, traintup
 	if err := db.UpdateIndex(indexName, oldAttributes, newAttributes); err != nil {
 		return err
 	}
+	if err := countTupleStatusUpdate(db, traintuple.Dataset.Worker, traintuple.Creator, oldStatus, newStatus); err != nil {
+		return err
+	}
 	if err := UpdateComputePlanState(db, traintuple.ComputePlanKey, newStatus, traintupleKey, traintuple.Dataset.Worker); err != nil {
 		return err
 	}
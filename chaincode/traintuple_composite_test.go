@@ -308,13 +308,15 @@ func TestTraintupleComposite(t *testing.T) {
 			Metadata:       map[string]string{},
 		},
 		OutHeadModel: outHeadModelComposite{
-			Permissions: outputPermissions{
-				Process: Permission{Public: false, AuthorizedIDs: []string{workerA}},
+			Permissions: outputPermissionsFull{
+				outputPermissions: outputPermissions{Process: Permission{Public: false, AuthorizedIDs: []string{workerA}, AuthorizedGroups: []string{}}},
+				Download:          Permission{Public: false, AuthorizedIDs: []string{workerA}, AuthorizedGroups: []string{}},
 			},
 		},
 		OutTrunkModel: outModelComposite{
-			Permissions: outputPermissions{
-				Process: Permission{Public: true, AuthorizedIDs: []string{}},
+			Permissions: outputPermissionsFull{
+				outputPermissions: outputPermissions{Process: Permission{Public: true, AuthorizedIDs: []string{}, AuthorizedGroups: []string{}}},
+				Download:          Permission{Public: true, AuthorizedIDs: []string{}, AuthorizedGroups: []string{}},
 			},
 		},
 		Metadata: map[string]string{},
@@ -355,8 +355,9 @@ func TestTraintuple(t *testing.T) {
 			Worker:         workerA,
 			Metadata:       map[string]string{},
 		},
-		Permissions: outputPermissions{
-			Process: Permission{Public: true, AuthorizedIDs: []string{}},
+		Permissions: outputPermissionsFull{
+			outputPermissions: outputPermissions{Process: Permission{Public: true, AuthorizedIDs: []string{}, AuthorizedGroups: []string{}}},
+			Download:          Permission{Public: true, AuthorizedIDs: []string{}, AuthorizedGroups: []string{}},
 		},
 		Metadata: map[string]string{},
 		Status:   StatusTodo,
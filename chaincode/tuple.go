@@ -17,6 +17,7 @@ package main
 import (
 	"chaincode/errors"
 	"encoding/json"
+	"strconv"
 )
 
 // List of the possible tuple's status
@@ -32,6 +33,14 @@ const (
 	StatusAborted = "canceled"
 )
 
+// GetWorker returns the node running the tuple
+func (tuple GenericTuple) GetWorker() string {
+	if tuple.Dataset != nil {
+		return tuple.Dataset.Worker
+	}
+	return tuple.Worker
+}
+
 // ------------------------------------------------
 // Smart contracts related to multiple tuple types
 // ------------------------------------------------
@@ -130,7 +139,7 @@ func queryModels(db *LedgerDB, args []string) (outModels []outputModelListItem,
 	}
 
 	// populate from regular traintuples
-	traintupleKeys, traintupleBookmark, err := db.GetIndexKeysWithPagination("traintuple~algo~key", []string{"traintuple"}, OutputPageSize/3, bookmarks.Traintuple)
+	traintupleKeys, traintupleBookmark, err := db.GetIndexKeysWithPagination("traintuple~algo~key", []string{"traintuple"}, db.config.PageSize/3, bookmarks.Traintuple)
 	bookmarks.Traintuple = traintupleBookmark
 
 	if err != nil {
@@ -150,7 +159,7 @@ func queryModels(db *LedgerDB, args []string) (outModels []outputModelListItem,
 	}
 
 	// populate from composite traintuples
-	compositeTraintupleKeys, compositeTraintupleBookmark, err := db.GetIndexKeysWithPagination("compositeTraintuple~algo~key", []string{"compositeTraintuple"}, OutputPageSize/3, bookmarks.CompositeTraintuple)
+	compositeTraintupleKeys, compositeTraintupleBookmark, err := db.GetIndexKeysWithPagination("compositeTraintuple~algo~key", []string{"compositeTraintuple"}, db.config.PageSize/3, bookmarks.CompositeTraintuple)
 	bookmarks.CompositeTraintuple = compositeTraintupleBookmark
 
 	if err != nil {
@@ -169,7 +178,7 @@ func queryModels(db *LedgerDB, args []string) (outModels []outputModelListItem,
 	}
 
 	// populate from composite traintuples
-	aggregatetupleKeys, aggregatetupleBookmark, err := db.GetIndexKeysWithPagination("aggregatetuple~algo~key", []string{"aggregatetuple"}, OutputPageSize/3, bookmarks.Aggregatetuple)
+	aggregatetupleKeys, aggregatetupleBookmark, err := db.GetIndexKeysWithPagination("aggregatetuple~algo~key", []string{"aggregatetuple"}, db.config.PageSize/3, bookmarks.Aggregatetuple)
 	bookmarks.Aggregatetuple = aggregatetupleBookmark
 
 	if err != nil {
@@ -199,7 +208,12 @@ func queryModel(db *LedgerDB, args []string) (outputModel, error) {
 	if err != nil {
 		return out, err
 	}
-	modelKey := inp.Key
+	return getOutputModel(db, inp.Key)
+}
+
+// getOutputModel returns the permissions, owner and storage address of a model
+func getOutputModel(db *LedgerDB, modelKey string) (outputModel, error) {
+	var out outputModel
 	keys, err := db.GetIndexKeys("tuple~modelKey~key", []string{"tuple", modelKey})
 	if err != nil {
 		return out, err
@@ -215,7 +229,8 @@ func queryModel(db *LedgerDB, args []string) (outputModel, error) {
 
 	// By default model is public processable
 	model := outputModel{
-		Key: modelKey,
+		Key:                   modelKey,
+		RevokedDataSampleKeys: []string{},
 	}
 
 	if tupleType == TraintupleType {
@@ -226,6 +241,10 @@ func queryModel(db *LedgerDB, args []string) (outputModel, error) {
 		model.Permissions.Fill(tuple.Permissions)
 		model.Owner = tuple.Dataset.Worker
 		model.StorageAddress = tuple.OutModel.StorageAddress
+		model.RevokedDataSampleKeys, err = getRevokedDataSampleKeys(db, tuple.Dataset.DataSampleKeys)
+		if err != nil {
+			return model, err
+		}
 	}
 
 	if tupleType == AggregatetupleType {
@@ -234,6 +253,7 @@ func queryModel(db *LedgerDB, args []string) (outputModel, error) {
 			return model, errors.Internal(err, "getModel: cannot get aggregatetuple")
 		}
 		model.Permissions.Fill(tuple.Permissions)
+		model.PermissionsRule = tuple.GetPermissionsRule()
 		model.Owner = tuple.Worker
 		model.StorageAddress = tuple.OutModel.StorageAddress
 	}
@@ -253,10 +273,155 @@ func queryModel(db *LedgerDB, args []string) (outputModel, error) {
 			model.StorageAddress = tuple.OutTrunkModel.OutModel.StorageAddress
 		}
 		model.Owner = tuple.Dataset.Worker
+		model.RevokedDataSampleKeys, err = getRevokedDataSampleKeys(db, tuple.Dataset.DataSampleKeys)
+		if err != nil {
+			return model, err
+		}
 	}
 	return model, nil
 }
 
+// queryModelProvenance returns every ancestor tuple of a model, walking the
+// in-models of traintuples and aggregatetuples and the head and trunk in-models
+// of composite traintuples. Tuples are listed breadth-first starting from the
+// tuple which produced the model, and paginated using an offset bookmark.
+func queryModelProvenance(db *LedgerDB, args []string) (outTuples []outputProvenanceTuple, bookmark string, err error) {
+	outTuples = []outputProvenanceTuple{}
+	inp := inputKeyBookmark{}
+	err = AssetFromJSON(args, &inp)
+	if err != nil {
+		return
+	}
+
+	offset := 0
+	if inp.Bookmark != "" {
+		offset, err = strconv.Atoi(inp.Bookmark)
+		if err != nil || offset < 0 {
+			err = errors.BadRequest("invalid bookmark %s", inp.Bookmark)
+			return
+		}
+	}
+
+	keys, err := db.GetIndexKeys("tuple~modelKey~key", []string{"tuple", inp.Key})
+	if err != nil {
+		return
+	}
+	if len(keys) == 0 {
+		err = errors.NotFound("Could not find a model for key %s", inp.Key)
+		return
+	}
+
+	// Only walk the DAG as far as needed to fill the requested page and to
+	// know whether there is a next one.
+	pageSize := int(db.config.PageSize)
+	limit := offset + pageSize + 1
+	depths := map[string]int{keys[0]: 0}
+	queue := []string{keys[0]}
+	for i := 0; i < len(queue) && i < limit; i++ {
+		var out outputProvenanceTuple
+		out, err = getOutputProvenanceTuple(db, queue[i])
+		if err != nil {
+			return
+		}
+		out.Depth = depths[queue[i]]
+		for _, parentKey := range out.Parents {
+			if _, ok := depths[parentKey]; ok {
+				continue
+			}
+			depths[parentKey] = out.Depth + 1
+			queue = append(queue, parentKey)
+		}
+		if i >= offset && len(outTuples) < pageSize {
+			outTuples = append(outTuples, out)
+		}
+	}
+
+	if len(queue) > offset+pageSize {
+		bookmark = strconv.Itoa(offset + pageSize)
+	}
+	return
+}
+
+// getOutputProvenanceTuple returns the provenance representation of a
+// traintuple, composite traintuple or aggregatetuple
+func getOutputProvenanceTuple(db *LedgerDB, key string) (out outputProvenanceTuple, err error) {
+	tupleType, err := db.GetAssetType(key)
+	if err != nil {
+		return
+	}
+	out.Key = key
+	out.Type = tupleType.String()
+	out.Parents = []string{}
+
+	switch tupleType {
+	case TraintupleType:
+		var tuple Traintuple
+		tuple, err = db.GetTraintuple(key)
+		if err != nil {
+			return
+		}
+		var algo Algo
+		algo, err = db.GetAlgo(tuple.AlgoKey)
+		if err != nil {
+			return
+		}
+		out.Algo = &KeyChecksum{Key: algo.Key, Checksum: algo.Checksum}
+		out.Dataset = newOutputProvenanceDataset(tuple.Dataset)
+		out.Worker = tuple.Dataset.Worker
+		out.Status = tuple.Status
+		if tuple.OutModel != nil {
+			out.OutModel = &KeyChecksum{Key: tuple.OutModel.Key, Checksum: tuple.OutModel.Checksum}
+		}
+		out.Parents = append(out.Parents, tuple.InModelKeys...)
+	case CompositeTraintupleType:
+		var tuple CompositeTraintuple
+		tuple, err = db.GetCompositeTraintuple(key)
+		if err != nil {
+			return
+		}
+		var algo CompositeAlgo
+		algo, err = db.GetCompositeAlgo(tuple.AlgoKey)
+		if err != nil {
+			return
+		}
+		out.Algo = &KeyChecksum{Key: algo.Key, Checksum: algo.Checksum}
+		out.Dataset = newOutputProvenanceDataset(tuple.Dataset)
+		out.Worker = tuple.Dataset.Worker
+		out.Status = tuple.Status
+		if tuple.OutTrunkModel.OutModel != nil {
+			out.OutModel = &KeyChecksum{Key: tuple.OutTrunkModel.OutModel.Key, Checksum: tuple.OutTrunkModel.OutModel.Checksum}
+		}
+		out.OutHeadModel = tuple.OutHeadModel.OutModel
+		if tuple.InHeadModel != "" {
+			out.Parents = append(out.Parents, tuple.InHeadModel)
+		}
+		if tuple.InTrunkModel != "" {
+			out.Parents = append(out.Parents, tuple.InTrunkModel)
+		}
+	case AggregatetupleType:
+		var tuple Aggregatetuple
+		tuple, err = db.GetAggregatetuple(key)
+		if err != nil {
+			return
+		}
+		var algo AggregateAlgo
+		algo, err = db.GetAggregateAlgo(tuple.AlgoKey)
+		if err != nil {
+			return
+		}
+		out.Algo = &KeyChecksum{Key: algo.Key, Checksum: algo.Checksum}
+		out.Worker = tuple.Worker
+		out.Status = tuple.Status
+		if tuple.OutModel != nil {
+			out.OutModel = &KeyChecksum{Key: tuple.OutModel.Key, Checksum: tuple.OutModel.Checksum}
+		}
+		out.Parents = append(out.Parents, tuple.InModelKeys...)
+	default:
+		err = errors.Internal("getOutputProvenanceTuple: unsupported tuple type %s", tupleType)
+	}
+	return
+}
+
 // ----------------------------------------------------------
 // Utils for smartcontracts related to  multiple tuple types
 // ----------------------------------------------------------
@@ -285,6 +450,16 @@ func checkUpdateTuple(db *LedgerDB, worker string, oldStatus string, newStatus s
 	if statusPossibilities[oldStatus] != newStatus && newStatus != StatusFailed {
 		return errors.BadRequest("cannot change status from %s to %s", oldStatus, newStatus)
 	}
+	// suspended nodes can finish their running tuples but cannot start new ones
+	if newStatus == StatusDoing {
+		node, err := db.GetNode(worker)
+		if err != nil {
+			return err
+		}
+		if node.GetStatus() != NodeStatusActive {
+			return errors.Forbidden("node %s is %s, it cannot start new tuples", worker, node.GetStatus())
+		}
+	}
 	return nil
 }
 
@@ -319,6 +494,51 @@ func determineTupleStatus(db *LedgerDB, tupleStatus, computePlanKey string) (str
 	return tupleStatus, nil
 }
 
+// cancelTuple cancels a todo or waiting tuple and propagates the cancellation
+// to its waiting children. It returns whether the tuple has been canceled.
+func cancelTuple(db *LedgerDB, tupleKey string) (bool, error) {
+	tuple, err := db.GetGenericTuple(tupleKey)
+	if err != nil {
+		return false, err
+	}
+	if !stringInSlice(tuple.Status, []string{StatusWaiting, StatusTodo}) {
+		return false, nil
+	}
+	updater, err := db.GetStatusUpdater(tupleKey)
+	if err != nil {
+		return false, err
+	}
+	if err := updater.saveStatusUpdate(db, tupleKey, StatusCanceled); err != nil {
+		return false, err
+	}
+	// the worker may already have picked up a todo tuple
+	if tuple.Status == StatusTodo {
+		if err := db.addTupleEvent(tuple.AssetType, tupleKey); err != nil {
+			return false, err
+		}
+	}
+	if tuple.AssetType == TesttupleType {
+		return true, nil
+	}
+	if err := UpdateTesttupleChildren(db, tupleKey, StatusCanceled); err != nil {
+		return false, err
+	}
+	if err := UpdateTraintupleChildren(db, tupleKey, StatusCanceled, []string{}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func createModelIndex(db *LedgerDB, modelKey, tupleKey string) error {
 	return db.CreateIndex("tuple~modelKey~key", []string{"tuple", modelKey, tupleKey})
 }
+
+// createDataSampleIndexes indexes a tuple under each of the data samples it uses
+func createDataSampleIndexes(db *LedgerDB, dataSampleKeys []string, tupleKey string) error {
+	for _, dataSampleKey := range dataSampleKeys {
+		if err := db.CreateIndex("tuple~dataSample~key", []string{"tuple", dataSampleKey, tupleKey}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -19,6 +19,16 @@ import (
 	"strconv"
 )
 
+// Rules deriving the permissions of an aggregatetuple out-model from its in-models
+const (
+	// PermissionsRuleUnion allows any node allowed by one of the in-models, and the creator
+	PermissionsRuleUnion = "union"
+	// PermissionsRuleIntersection allows the nodes allowed by all the in-models
+	PermissionsRuleIntersection = "intersection"
+	// PermissionsRuleOverride sets explicit permissions, which all the in-models must allow
+	PermissionsRuleOverride = "override"
+)
+
 // -------------------------------------------------------------------------------------------
 // Methods on receivers Aggregatetuple
 // -------------------------------------------------------------------------------------------
@@ -38,6 +48,10 @@ func (tuple *Aggregatetuple) SetFromInput(db *LedgerDB, inp inputAggregatetuple)
 	tuple.Key = inp.Key
 	tuple.AssetType = AggregatetupleType
 	tuple.Creator = creator
+	err = checkMetadata(db, inp.Metadata)
+	if err != nil {
+		return err
+	}
 	tuple.Metadata = inp.Metadata
 	tuple.Tag = inp.Tag
 	tuple.ComputePlanKey = inp.ComputePlanKey
@@ -45,34 +59,69 @@ func (tuple *Aggregatetuple) SetFromInput(db *LedgerDB, inp inputAggregatetuple)
 	if err != nil {
 		return errors.BadRequest(err, "could not retrieve algo with key %s", inp.AlgoKey)
 	}
-	if !algo.Permissions.CanProcess(algo.Owner, creator) {
+	canProcess, err := algo.Permissions.CanProcess(db, algo.Owner, creator)
+	if err != nil {
+		return err
+	}
+	if !canProcess {
 		return errors.Forbidden("not authorized to process algo %s", inp.AlgoKey)
 	}
+	if err := algo.checkUsable(); err != nil {
+		return err
+	}
 	tuple.AlgoKey = inp.AlgoKey
+	worker := inp.Worker
+	if inp.WorkerSelector != nil {
+		if inp.Worker != "" {
+			return errors.BadRequest("worker and worker_selector cannot be both set")
+		}
+		worker, err = selectWorker(db, *inp.WorkerSelector, inp.Key)
+		if err != nil {
+			return err
+		}
+	}
 	// Check if worker is a valid node
-	_, err = db.GetNode(inp.Worker)
+	node, err := db.GetNode(worker)
 	if err != nil {
-		return errors.BadRequest(err, "could not retrieve worker %s", inp.Worker)
+		return errors.BadRequest(err, "could not retrieve worker %s", worker)
+	}
+	if node.GetStatus() != NodeStatusActive {
+		return errors.BadRequest("worker %s is %s", worker, node.GetStatus())
+	}
+	tuple.Worker = worker
+	tuple.PermissionsRule = inp.OutModelPermissionsRule
+	if tuple.PermissionsRule == "" {
+		tuple.PermissionsRule = PermissionsRuleUnion
+	}
+	if inp.OutModelPermissions != nil && tuple.PermissionsRule != PermissionsRuleOverride {
+		return errors.BadRequest("out_model_permissions can only be set with the %s rule", PermissionsRuleOverride)
 	}
-	tuple.Worker = inp.Worker
 	return nil
 }
 
+// GetPermissionsRule returns the rule deriving the out-model permissions.
+// Aggregatetuples registered before rules existed used the union.
+func (tuple *Aggregatetuple) GetPermissionsRule() string {
+	if tuple.PermissionsRule == "" {
+		return PermissionsRuleUnion
+	}
+	return tuple.PermissionsRule
+}
+
 // SetFromParents set the status of the aggregate tuple depending on its "parents",
 // i.e. the traintuples from which it received the outModels as inModels.
-// Also it's InModelKeys are set.
-func (tuple *Aggregatetuple) SetFromParents(db *LedgerDB, inModels []string) error {
+// Also it's InModelKeys are set, and its permissions derived from the parents'
+// ones following its PermissionsRule. It returns the data managers the in-models
+// were trained on, for the checks of the compute plan.
+func (tuple *Aggregatetuple) SetFromParents(db *LedgerDB, inModels []string, outModelPermissions *inputPermissions) ([]DataManager, error) {
 	var parentStatuses []string
+	var parentsPermissions []Permissions
 	inModelKeys := tuple.InModelKeys
-	permissions, err := NewPermissions(db, inputPermissions{})
-	if err != nil {
-		return errors.BadRequest(err, "could not generate open permissions")
-	}
 
 	for _, parentTraintupleKey := range inModels {
 		parentType, err := db.GetAssetType(parentTraintupleKey)
 		if err != nil {
-			return errors.Internal("could not retrieve traintuple type with key %s - %s", parentTraintupleKey, err.Error())
+			return nil, errors.Internal("could not retrieve traintuple type with key %s - %s", parentTraintupleKey, err.Error())
 		}
 
 		parentPermissions := Permissions{}
@@ -98,19 +147,168 @@ func (tuple *Aggregatetuple) SetFromParents(db *LedgerDB, inModels []string) err
 				parentStatuses = append(parentStatuses, tuple.Status)
 			}
 		default:
-			return errors.Internal("aggregate.SetFromParents: Unsupported parent type %s", parentType)
+			return nil, errors.Internal("aggregate.SetFromParents: Unsupported parent type %s", parentType)
 		}
 
 		if err != nil {
-			return errors.Internal("could not retrieve traintuple type with key %s - %s", parentTraintupleKey, err.Error())
+			return nil, errors.Internal("could not retrieve traintuple type with key %s - %s", parentTraintupleKey, err.Error())
 		}
 
 		inModelKeys = append(inModelKeys, parentTraintupleKey)
-		permissions = UnionPermissions(permissions, parentPermissions)
+		parentsPermissions = append(parentsPermissions, parentPermissions)
+	}
+	permissions, err := tuple.derivePermissions(db, parentsPermissions, outModelPermissions)
+	if err != nil {
+		return nil, err
+	}
+	// models trained on data kept private cannot become public once aggregated.
+	// The restriction is built from every private policy and allows all their owners.
+	dataManagers, err := getInModelsDataManagers(db, inModels)
+	if err != nil {
+		return nil, err
 	}
+	privatePolicy := &UsagePolicy{}
+	nodeIDs := []string{tuple.Creator, tuple.Worker}
+	for _, dataManager := range dataManagers {
+		if dataManager.UsagePolicy != nil && dataManager.UsagePolicy.PrivateModels {
+			privatePolicy.PrivateModels = true
+			nodeIDs = append(nodeIDs, dataManager.Owner)
+		}
+	}
+	permissions = privatePolicy.restrictModelPermissions(permissions, nodeIDs)
 	tuple.Status = determineStatusFromInModels(parentStatuses)
 	tuple.InModelKeys = inModelKeys
+	if err := tuple.checkMinAggregationWorkers(db, dataManagers, 0); err != nil {
+		return nil, err
+	}
 	tuple.Permissions = permissions
+	return dataManagers, nil
+}
+
+// derivePermissions computes the out-model permissions from the in-models' ones:
+//  - union: the creator and any node allowed by one of the in-models
+//  - intersection: the nodes allowed by all the in-models
+//  - override: the given permissions, as long as all the in-models allow them
+// Without in-models, the out-model is private to the creator.
+func (tuple *Aggregatetuple) derivePermissions(db *LedgerDB, parentsPermissions []Permissions, override *inputPermissions) (Permissions, error) {
+	creatorPermissions, err := NewPermissions(db, inputPermissions{})
+	if err != nil {
+		return Permissions{}, errors.BadRequest(err, "could not generate private permissions")
+	}
+	if len(parentsPermissions) == 0 && tuple.PermissionsRule != PermissionsRuleOverride {
+		return creatorPermissions, nil
+	}
+
+	union := creatorPermissions
+	intersection := Permissions{
+		Process:  Permission{Public: true, AuthorizedIDs: []string{}},
+		Download: Permission{Public: true, AuthorizedIDs: []string{}},
+	}
+	for _, parentPermissions := range parentsPermissions {
+		union, err = UnionPermissions(db, union, parentPermissions)
+		if err != nil {
+			return Permissions{}, err
+		}
+		intersection, err = MergePermissions(db, intersection, parentPermissions)
+		if err != nil {
+			return Permissions{}, err
+		}
+	}
+
+	switch tuple.PermissionsRule {
+	case PermissionsRuleIntersection:
+		return intersection, nil
+	case PermissionsRuleOverride:
+		if override == nil {
+			return Permissions{}, errors.BadRequest("out_model_permissions are required with the %s rule", PermissionsRuleOverride)
+		}
+		permissions, err := NewPermissions(db, *override)
+		if err != nil {
+			return Permissions{}, err
+		}
+		expanded, err := permissions.expandGroups(db)
+		if err != nil {
+			return Permissions{}, err
+		}
+		// the creator is always allowed on the out-model
+		allowed, err := UnionPermissions(db, intersection, creatorPermissions)
+		if err != nil {
+			return Permissions{}, err
+		}
+		if !allowed.Process.include(expanded.Process) || !allowed.Download.include(expanded.Download) {
+			return Permissions{}, errors.Forbidden("out_model_permissions exceed what the in-models allow")
+		}
+		return permissions, nil
+	default:
+		return union, nil
+	}
+}
+
+// getInModelsDataManagers returns the data managers the in-models were trained
+// on, following the in-models of aggregate tuples
+func getInModelsDataManagers(db *LedgerDB, inModelKeys []string) ([]DataManager, error) {
+	dataManagers := []DataManager{}
+	visited := map[string]bool{}
+	var visit func(keys []string) error
+	visit = func(keys []string) error {
+		for _, key := range keys {
+			if visited[key] {
+				continue
+			}
+			visited[key] = true
+			parent, err := db.GetGenericTuple(key)
+			if err != nil {
+				return errors.BadRequest(err, "could not retrieve in-model %s", key)
+			}
+			if parent.AssetType == AggregatetupleType {
+				aggregatetuple, err := db.GetAggregatetuple(key)
+				if err != nil {
+					return err
+				}
+				if err := visit(aggregatetuple.InModelKeys); err != nil {
+					return err
+				}
+				continue
+			}
+			if parent.Dataset == nil || visited[parent.Dataset.DataManagerKey] {
+				continue
+			}
+			visited[parent.Dataset.DataManagerKey] = true
+			dataManager, err := db.GetDataManager(parent.Dataset.DataManagerKey)
+			if err != nil {
+				return err
+			}
+			dataManagers = append(dataManagers, dataManager)
+		}
+		return nil
+	}
+	err := visit(inModelKeys)
+	return dataManagers, err
+}
+
+// checkMinAggregationWorkers returns an error if the in-models come from fewer distinct
+// workers than minWorkers or than required by the usage policies of the data managers
+// the in-models were trained on, directly or through aggregate in-models
+func (tuple *Aggregatetuple) checkMinAggregationWorkers(db *LedgerDB, dataManagers []DataManager, minWorkers int) error {
+	workers := []string{}
+	for _, inModel := range tuple.InModelKeys {
+		parent, err := db.GetGenericTuple(inModel)
+		if err != nil {
+			return errors.BadRequest(err, "could not retrieve in-model %s", inModel)
+		}
+		worker := parent.GetWorker()
+		if !stringInSlice(worker, workers) {
+			workers = append(workers, worker)
+		}
+	}
+	for _, dataManager := range dataManagers {
+		if dataManager.UsagePolicy != nil && dataManager.UsagePolicy.MinAggregationWorkers > minWorkers {
+			minWorkers = dataManager.UsagePolicy.MinAggregationWorkers
+		}
+	}
+	if len(workers) < minWorkers {
+		return errors.BadRequest("aggregatetuple %s aggregates models from %d distinct workers, at least %d are required", tuple.Key, len(workers), minWorkers)
+	}
 	return nil
 }
 
@@ -120,7 +318,7 @@ func (tuple *Aggregatetuple) SetFromParents(db *LedgerDB, inModels []string) err
 //  - If rank is 0 and ComputePlanKey empty, it's start a new one using this traintuple key
 //  - If rank and ComputePlanKey are set, it checks if there are coherent with previous ones and set it.
 // Use checkComputePlanAvailability to ensure the compute plan exists and no other tuple is registered with the same worker/rank
-func (tuple *Aggregatetuple) AddToComputePlan(db *LedgerDB, inp inputAggregatetuple, traintupleKey string, checkComputePlanAvailability bool) error {
+func (tuple *Aggregatetuple) AddToComputePlan(db *LedgerDB, inp inputAggregatetuple, traintupleKey string, dataManagers []DataManager, checkComputePlanAvailability bool) error {
 	// check ComputePlanKey and Rank and set it when required
 	var err error
 	if inp.Rank == "" {
@@ -139,6 +337,11 @@ func (tuple *Aggregatetuple) AddToComputePlan(db *LedgerDB, inp inputAggregatetu
 	if err != nil {
 		return err
 	}
+	if computePlan.MinAggregationWorkers > 0 {
+		if err := tuple.checkMinAggregationWorkers(db, dataManagers, computePlan.MinAggregationWorkers); err != nil {
+			return err
+		}
+	}
 	err = computePlan.AddTuple(db, AggregatetupleType, traintupleKey, tuple.Status, tuple.Worker)
 	if err != nil {
 		return err
@@ -228,7 +431,7 @@ func createAggregatetupleInternal(db *LedgerDB, inp inputAggregatetuple, checkCo
 	if err != nil {
 		return "", err
 	}
-	err = aggregatetuple.SetFromParents(db, inp.InModels)
+	dataManagers, err := aggregatetuple.SetFromParents(db, inp.InModels, inp.OutModelPermissions)
 	if err != nil {
 		return "", err
 	}
@@ -241,7 +444,7 @@ func createAggregatetupleInternal(db *LedgerDB, inp inputAggregatetuple, checkCo
 	if tupleExists {
 		return "", errors.Conflict("aggregatetuple already exists").WithKey(aggregatetuple.Key)
 	}
-	err = aggregatetuple.AddToComputePlan(db, inp, aggregatetuple.Key, checkComputePlanAvailability)
+	err = aggregatetuple.AddToComputePlan(db, inp, aggregatetuple.Key, dataManagers, checkComputePlanAvailability)
 	if err != nil {
 		return "", err
 	}
@@ -276,6 +479,9 @@ func logStartAggregate(db *LedgerDB, args []string) (o outputAggregatetuple, err
 	if err = validateTupleOwner(db, aggregatetuple.Worker); err != nil {
 		return
 	}
+	if err = checkSecureAggregationDone(db, aggregatetuple); err != nil {
+		return
+	}
 	if err = aggregatetuple.commitStatusUpdate(db, inp.Key, status); err != nil {
 		return
 	}
@@ -298,7 +504,10 @@ func logFailAggregate(db *LedgerDB, args []string) (o outputAggregatetuple, err
 		return
 	}
 
-	aggregatetuple.Log += inp.Log
+	aggregatetuple.Log, err = appendLog(db, aggregatetuple.Log, inp.Log)
+	if err != nil {
+		return
+	}
 
 	if err = validateTupleOwner(db, aggregatetuple.Worker); err != nil {
 		return
@@ -343,7 +552,10 @@ func logSuccessAggregate(db *LedgerDB, args []string) (o outputAggregatetuple, e
 		Key:            inp.OutModel.Key,
 		Checksum:       inp.OutModel.Checksum,
 		StorageAddress: inp.OutModel.StorageAddress}
-	aggregatetuple.Log += inp.Log
+	aggregatetuple.Log, err = appendLog(db, aggregatetuple.Log, inp.Log)
+	if err != nil {
+		return
+	}
 
 	err = createModelIndex(db, inp.OutModel.Key, aggregatetupleKey)
 	if err != nil {
@@ -413,7 +625,7 @@ func queryAggregatetuples(db *LedgerDB, args []string) (outputAggregatetuples []
 		}
 	}
 
-	elementsKeys, bookmark, err := db.GetIndexKeysWithPagination("aggregatetuple~algo~key", []string{"aggregatetuple"}, OutputPageSize, inp.Bookmark)
+	elementsKeys, bookmark, err := db.GetIndexKeysWithPagination("aggregatetuple~algo~key", []string{"aggregatetuple"}, db.config.PageSize, inp.Bookmark)
 
 	if err != nil {
 		return
@@ -454,8 +666,8 @@ func UpdateAggregatetupleChild(db *LedgerDB, parentAggregatetupleKey string, chi
 
 	// get traintuple new status
 	var newStatus string
-	if aggregatetupleStatus == StatusFailed {
-		newStatus = StatusFailed
+	if stringInSlice(aggregatetupleStatus, []string{StatusFailed, StatusAborted}) {
+		newStatus = aggregatetupleStatus
 	} else if aggregatetupleStatus == StatusDone {
 		ready, _err := childAggregatetuple.isReady(db, parentAggregatetupleKey)
 		if _err != nil {
@@ -510,6 +722,12 @@ func (tuple *Aggregatetuple) commitStatusUpdate(db *LedgerDB, aggregatetupleKey
 		return nil
 	}
 
+	return tuple.saveStatusUpdate(db, aggregatetupleKey, newStatus)
+}
+
+// saveStatusUpdate updates the aggregatetuple status in the ledger, without
+// restricting the statuses it can be aborted from
+func (tuple *Aggregatetuple) saveStatusUpdate(db *LedgerDB, aggregatetupleKey string, newStatus string) error {
 	if err := tuple.validateNewStatus(db, newStatus); err != nil {
 		return errors.Internal("update aggregatetuple %s failed: %s", aggregatetupleKey, err.Error())
 	}
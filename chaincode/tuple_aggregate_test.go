@@ -16,6 +16,7 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
 	"testing"
@@ -271,13 +272,22 @@ func TestTraintupleAggregate(t *testing.T) {
 		Creator: workerA,
 		Worker:  workerA,
 		Status:  StatusTodo,
-		Permissions: outputPermissions{
-			Process: Permission{
-				Public:        false,
-				AuthorizedIDs: []string{workerA},
+		Permissions: outputPermissionsFull{
+			outputPermissions: outputPermissions{
+				Process: Permission{
+					Public:           false,
+					AuthorizedIDs:    []string{workerA},
+					AuthorizedGroups: []string{},
+				},
+			},
+			Download: Permission{
+				Public:           false,
+				AuthorizedIDs:    []string{workerA},
+				AuthorizedGroups: []string{},
 			},
 		},
-		Metadata: map[string]string{},
+		Metadata:        map[string]string{},
+		PermissionsRule: PermissionsRuleUnion,
 	}
 	assert.Exactly(t, expected, out, "the aggregate tuple queried from the ledger differ from expected")
 
@@ -636,3 +646,180 @@ func TestCreateFailedAggregate(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, StatusFailed, out.Status)
 }
+
+func TestAggregatetupleWorkerSelection(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	registerItem(t, *mockStub, "compositeTraintuple")
+	mockStub.MockTransactionStart(mockTxID)
+	db := NewLedgerDB(mockStub)
+
+	mockStub.Creator = workerB
+	_, err := registerNode(db, assetToArgs(inputNode{Capabilities: inputNodeCapabilities{MaxMemory: 64000, DataManagerTypes: []string{"images"}}}))
+	require.NoError(t, err)
+	mockStub.Creator = workerC
+	_, err = registerNode(db, assetToArgs(inputNode{Capabilities: inputNodeCapabilities{MaxMemory: 64000, DataManagerTypes: []string{"images"}}}))
+	require.NoError(t, err)
+	_, err = updateNodeStatus(db, assetToArgs(inputUpdateNodeStatus{NodeID: workerC, Status: NodeStatusSuspended}))
+	require.NoError(t, err)
+	mockStub.Creator = workerA
+
+	testTable := []struct {
+		name     string
+		worker   string
+		selector *inputWorkerSelector
+		expected string
+	}{
+		{"unknown worker", "unknown node", nil, ""},
+		{"suspended worker", workerC, nil, ""},
+		{"worker and selector", workerB, &inputWorkerSelector{DataManagerType: "images"}, ""},
+		{"selector resolved to the only active node", "", &inputWorkerSelector{DataManagerType: "images"}, workerB},
+		{"selector on memory", "", &inputWorkerSelector{MinMemory: 32000}, workerB},
+		{"selector matching no node", "", &inputWorkerSelector{DataManagerType: "tabular"}, ""},
+	}
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			in := inputAggregatetuple{Key: RandomUUID()}
+			in.fillDefaults()
+			in.Worker = test.worker
+			in.WorkerSelector = test.selector
+			in.InModels = []string{compositeTraintupleKey}
+			key, err := createAggregatetupleInternal(db, in, true)
+			if test.expected == "" {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			tuple, err := db.GetAggregatetuple(key)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, tuple.Worker)
+		})
+	}
+}
+
+func TestAggregatetupleMinAggregationWorkers(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	registerWorker(mockStub, workerB)
+	registerItem(t, *mockStub, "traintuple")
+	mockStub.MockTransactionStart(mockTxID)
+	db := NewLedgerDB(mockStub)
+
+	// an aggregatetuple run by workerB, without any in-model
+	fromWorkerB := inputAggregatetuple{Key: RandomUUID(), Worker: workerB}
+	fromWorkerB.fillDefaults()
+	_, err := createAggregatetuple(db, assetToArgs(fromWorkerB))
+	require.NoError(t, err)
+
+	// the compute plan requires 2 distinct workers
+	_, err = createComputePlanInternal(db, inputComputePlan{Key: computePlanKey, MinAggregationWorkers: 2}, "", nil, false)
+	require.NoError(t, err)
+	inp := inputAggregatetuple{Key: RandomUUID(), InModels: []string{traintupleKey}, ComputePlanKey: computePlanKey, Rank: "0"}
+	inp.fillDefaults()
+	_, err = createAggregatetuple(db, assetToArgs(inp))
+	assert.Error(t, err, "a single contributing worker is not enough for the compute plan")
+	inp.InModels = []string{traintupleKey, fromWorkerB.Key}
+	_, err = createAggregatetuple(db, assetToArgs(inp))
+	assert.NoError(t, err)
+	aggregatedKey := inp.Key
+
+	// the usage policy of the traintuple's data manager requires 3 distinct workers
+	_, err = updateUsagePolicy(db, assetToArgs(inputUpdateUsagePolicy{DataManagerKey: dataManagerKey, UsagePolicy: &inputUsagePolicy{MinAggregationWorkers: 3}}))
+	require.NoError(t, err)
+	inp = inputAggregatetuple{Key: RandomUUID(), InModels: []string{traintupleKey, fromWorkerB.Key}}
+	inp.fillDefaults()
+	_, err = createAggregatetuple(db, assetToArgs(inp))
+	assert.Error(t, err, "two contributing workers are not enough for the data manager")
+	inp.InModels = []string{aggregatedKey, fromWorkerB.Key}
+	_, err = createAggregatetuple(db, assetToArgs(inp))
+	assert.Error(t, err, "the policy applies to models aggregated from the data manager")
+	inp.InModels = []string{fromWorkerB.Key}
+	_, err = createAggregatetuple(db, assetToArgs(inp))
+	assert.NoError(t, err, "the policy only applies to models trained on the data manager")
+}
+
+func TestAggregatetuplePermissionsRules(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	registerWorker(mockStub, "nodeB")
+	registerWorker(mockStub, "nodeC")
+	registerItem(t, *mockStub, "traintuple")
+	mockStub.MockTransactionStart(mockTxID)
+	db := NewLedgerDB(mockStub)
+
+	// the traintuple out-model is public, the composite trunk is private to
+	// workerA, nodeB and nodeC, and the aggregate one to workerA and nodeC
+	composite := inputCompositeTraintuple{Key: RandomUUID()}
+	composite.fillDefaults()
+	composite.OutTrunkModelPermissions = inputPermissions{Process: inputPermission{AuthorizedIDs: []string{"nodeB", "nodeC"}}}
+	_, err := createCompositeTraintuple(db, assetToArgs(composite))
+	require.NoError(t, err)
+	aggregate := inputAggregatetuple{
+		Key:                     RandomUUID(),
+		InModels:                []string{composite.Key},
+		OutModelPermissionsRule: PermissionsRuleOverride,
+		OutModelPermissions:     &inputPermissions{Process: inputPermission{AuthorizedIDs: []string{"nodeC"}}},
+	}
+	aggregate.fillDefaults()
+	_, err = createAggregatetuple(db, assetToArgs(aggregate))
+	require.NoError(t, err)
+
+	parents := map[string]string{"traintuple": traintupleKey, "composite": composite.Key, "aggregate": aggregate.Key}
+	overrideB := &inputPermissions{Process: inputPermission{AuthorizedIDs: []string{"nodeB"}}}
+	overrideC := &inputPermissions{Process: inputPermission{AuthorizedIDs: []string{"nodeC"}}}
+	public := []string{}
+	testTable := []struct {
+		parents  []string
+		rule     string
+		override *inputPermissions
+		expected []string // nil if the creation fails, empty if public
+	}{
+		{[]string{"traintuple"}, "", nil, public},
+		{[]string{"composite"}, "", nil, []string{workerA, "nodeB", "nodeC"}},
+		{[]string{"aggregate"}, "", nil, []string{workerA, "nodeC"}},
+		{[]string{"composite", "aggregate"}, PermissionsRuleUnion, nil, []string{workerA, "nodeB", "nodeC"}},
+		{[]string{"traintuple", "aggregate"}, PermissionsRuleUnion, nil, public},
+		{[]string{"traintuple", "composite", "aggregate"}, PermissionsRuleUnion, nil, public},
+		{[]string{"traintuple"}, PermissionsRuleIntersection, nil, public},
+		{[]string{"traintuple", "composite"}, PermissionsRuleIntersection, nil, []string{workerA, "nodeB", "nodeC"}},
+		{[]string{"traintuple", "aggregate"}, PermissionsRuleIntersection, nil, []string{workerA, "nodeC"}},
+		{[]string{"composite", "aggregate"}, PermissionsRuleIntersection, nil, []string{workerA, "nodeC"}},
+		{[]string{"traintuple", "composite", "aggregate"}, PermissionsRuleIntersection, nil, []string{workerA, "nodeC"}},
+		{[]string{"traintuple"}, PermissionsRuleOverride, overrideB, []string{workerA, "nodeB"}},
+		{[]string{"traintuple", "composite"}, PermissionsRuleOverride, overrideB, []string{workerA, "nodeB"}},
+		{[]string{"composite", "aggregate"}, PermissionsRuleOverride, overrideB, nil},
+		{[]string{"traintuple", "aggregate"}, PermissionsRuleOverride, overrideB, nil},
+		{[]string{"traintuple", "composite", "aggregate"}, PermissionsRuleOverride, overrideC, []string{workerA, "nodeC"}},
+		{[]string{"traintuple"}, PermissionsRuleOverride, &inputPermissions{Process: inputPermission{Public: true, AuthorizedIDs: []string{}}}, public},
+		{[]string{"composite"}, PermissionsRuleOverride, &inputPermissions{Process: inputPermission{Public: true, AuthorizedIDs: []string{}}}, nil},
+		{[]string{"composite"}, PermissionsRuleOverride, nil, nil},
+		{[]string{"composite"}, PermissionsRuleUnion, overrideC, nil},
+	}
+	for _, tt := range testTable {
+		name := fmt.Sprintf("%s rule on %v", tt.rule, tt.parents)
+		t.Run(name, func(t *testing.T) {
+			inp := inputAggregatetuple{Key: RandomUUID(), OutModelPermissionsRule: tt.rule, OutModelPermissions: tt.override}
+			inp.fillDefaults()
+			for _, parent := range tt.parents {
+				inp.InModels = append(inp.InModels, parents[parent])
+			}
+			_, err := createAggregatetuple(db, assetToArgs(inp))
+			if tt.expected == nil {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			out, err := queryAggregatetuple(db, keyToArgs(inp.Key))
+			require.NoError(t, err)
+			expectedRule := tt.rule
+			if expectedRule == "" {
+				expectedRule = PermissionsRuleUnion
+			}
+			assert.Equal(t, expectedRule, out.PermissionsRule)
+			assert.Equal(t, len(tt.expected) == 0, out.Permissions.Process.Public)
+			if len(tt.expected) > 0 {
+				assert.True(t, sameStringSlice(tt.expected, out.Permissions.Process.AuthorizedIDs), out.Permissions.Process.AuthorizedIDs)
+			}
+		})
+	}
+}
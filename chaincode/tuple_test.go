@@ -342,3 +342,76 @@ func TestQueryModelsPagination(t *testing.T) {
 	newFirstResult := models.Results[0].Traintuple.Key
 	assert.NotEqual(t, newFirstResult, firstResult, "query results should be different")
 }
+
+func TestQueryModelProvenance(t *testing.T) {
+	scc := new(SubstraChaincode)
+	mockStub := NewMockStubWithRegisterNode("substra", scc)
+	registerItem(t, *mockStub, "traintuple")
+	mockStub.MockTransactionStart("42")
+	db := NewLedgerDB(mockStub)
+
+	childTraintuple := inputTraintuple{Key: RandomUUID()}
+	childTraintuple.createDefault()
+	childTraintuple.InModels = []string{traintupleKey}
+	_, err := createTraintuple(db, assetToArgs(childTraintuple))
+	require.NoError(t, err)
+
+	inpAggregatetuple := inputAggregatetuple{}
+	inpAggregatetuple.fillDefaults()
+	inpAggregatetuple.InModels = []string{traintupleKey, childTraintuple.Key}
+	_, err = createAggregatetuple(db, assetToArgs(inpAggregatetuple))
+	require.NoError(t, err)
+
+	traintupleToDone(t, db, traintupleKey)
+	childModelKey := RandomUUID()
+	_, err = logStartTrain(db, assetToArgs(inputKey{Key: childTraintuple.Key}))
+	require.NoError(t, err)
+	success := inputLogSuccessTrain{}
+	success.Key = childTraintuple.Key
+	success.fillDefaults()
+	success.OutModel.Key = childModelKey
+	_, err = logSuccessTrain(db, assetToArgs(success))
+	require.NoError(t, err)
+	aggregateModelKey := RandomUUID()
+	aggregateToDone(t, mockStub, workerA, db, aggregatetupleKey, aggregateModelKey)
+
+	tuples, bookmark, err := queryModelProvenance(db, assetToArgs(inputKeyBookmark{Key: aggregateModelKey}))
+	require.NoError(t, err)
+	assert.Equal(t, "", bookmark)
+	require.Len(t, tuples, 3)
+
+	assert.Equal(t, aggregatetupleKey, tuples[0].Key)
+	assert.Equal(t, AggregatetupleType.String(), tuples[0].Type)
+	assert.Equal(t, 0, tuples[0].Depth)
+	assert.Equal(t, aggregateModelKey, tuples[0].OutModel.Key)
+	assert.Equal(t, []string{traintupleKey, childTraintuple.Key}, tuples[0].Parents)
+	assert.Nil(t, tuples[0].Dataset)
+
+	// the root traintuple is reachable from both the aggregatetuple and its
+	// child but is only listed once, at its shortest distance
+	assert.Equal(t, traintupleKey, tuples[1].Key)
+	assert.Equal(t, 1, tuples[1].Depth)
+	assert.Equal(t, modelKey, tuples[1].OutModel.Key)
+	assert.Equal(t, algoKey, tuples[1].Algo.Key)
+	assert.Equal(t, workerA, tuples[1].Worker)
+	assert.Equal(t, dataManagerKey, tuples[1].Dataset.DataManagerKey)
+	assert.Equal(t, []string{trainDataSampleKey1, trainDataSampleKey2}, tuples[1].Dataset.DataSampleKeys)
+	assert.Empty(t, tuples[1].Parents)
+
+	assert.Equal(t, childTraintuple.Key, tuples[2].Key)
+	assert.Equal(t, 1, tuples[2].Depth)
+	assert.Equal(t, childModelKey, tuples[2].OutModel.Key)
+	assert.Equal(t, []string{traintupleKey}, tuples[2].Parents)
+
+	// a bookmark past the end returns an empty page
+	tuples, bookmark, err = queryModelProvenance(db, assetToArgs(inputKeyBookmark{Key: aggregateModelKey, Bookmark: "3"}))
+	require.NoError(t, err)
+	assert.Empty(t, tuples)
+	assert.Equal(t, "", bookmark)
+
+	_, _, err = queryModelProvenance(db, assetToArgs(inputKeyBookmark{Key: aggregateModelKey, Bookmark: "foo"}))
+	assert.Error(t, err)
+
+	_, _, err = queryModelProvenance(db, assetToArgs(inputKeyBookmark{Key: RandomUUID()}))
+	assert.Error(t, err)
+}
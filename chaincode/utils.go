@@ -108,6 +108,12 @@ func (assetType AssetType) String() string {
 		return "testtuple"
 	case ComputePlanType:
 		return "compute_plan"
+	case NodeGroupType:
+		return "node_group"
+	case ProposalType:
+		return "proposal"
+	case SecureAggregationType:
+		return "secure_aggregation"
 	default:
 		return fmt.Sprintf("(unknown asset type: %d)", assetType)
 	}